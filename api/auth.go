@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+)
+
+type (
+	AuthMode string
+
+	// AuthConfig describes how the client authenticates to the api.
+	AuthConfig struct {
+		Mode AuthMode
+
+		// Offline (refresh) token used to acquire access tokens in
+		// the rhsso and custom-oidc modes.
+		OfflineToken string
+
+		// Bearer token sent with every request in the static-bearer
+		// mode.
+		Token string
+
+		// Token endpoint and client id used in the custom-oidc
+		// mode. In the rhsso mode these default to the Red Hat SSO
+		// service.
+		TokenUrl string
+		ClientID string
+	}
+)
+
+const (
+	// Exchange an offline token for access tokens using Red Hat SSO.
+	AuthModeRHSSO AuthMode = "rhsso"
+
+	// Send no credentials (for assisted-service deployments with
+	// authentication disabled).
+	AuthModeNone AuthMode = "none"
+
+	// Send a fixed bearer token.
+	AuthModeStaticBearer AuthMode = "static-bearer"
+
+	// Exchange an offline token for access tokens using an
+	// arbitrary OpenID Connect token endpoint.
+	AuthModeCustomOIDC AuthMode = "custom-oidc"
+)
+
+var defaultClientID = "cloud-services"
+
+var supportedAuthModes = []string{
+	string(AuthModeRHSSO),
+	string(AuthModeNone),
+	string(AuthModeStaticBearer),
+	string(AuthModeCustomOIDC),
+}
+
+func ValidateAuthMode(mode string) bool {
+	return ValInList(mode, supportedAuthModes)
+}
+
+// Check that the configuration has everything required by the
+// selected mode, and fill in defaults.
+func (auth *AuthConfig) validate() error {
+	if auth.Mode == "" {
+		auth.Mode = AuthModeRHSSO
+	}
+
+	if !ValidateAuthMode(string(auth.Mode)) {
+		return fmt.Errorf("invalid auth mode: %s", auth.Mode)
+	}
+
+	switch auth.Mode {
+	case AuthModeRHSSO:
+		if auth.TokenUrl == "" {
+			auth.TokenUrl = ssourl
+		}
+		if auth.ClientID == "" {
+			auth.ClientID = defaultClientID
+		}
+	case AuthModeCustomOIDC:
+		if auth.TokenUrl == "" {
+			return fmt.Errorf("auth mode %s requires a token url", auth.Mode)
+		}
+		if auth.ClientID == "" {
+			auth.ClientID = defaultClientID
+		}
+	case AuthModeStaticBearer:
+		if auth.Token == "" {
+			return fmt.Errorf("auth mode %s requires a token", auth.Mode)
+		}
+	}
+
+	return nil
+}
+
+// Returns true if this configuration acquires access tokens using an
+// offline token (and so can refresh them when they expire).
+func (auth *AuthConfig) refreshable() bool {
+	return auth.Mode == AuthModeRHSSO || auth.Mode == AuthModeCustomOIDC
+}
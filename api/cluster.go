@@ -1,11 +1,13 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -29,6 +31,18 @@ var supportedNetworkTypes = []string{
 	"OVNKubernetes",
 }
 
+var supportedHighAvailabilityModes = []string{
+	"Full",
+	"None",
+}
+
+var supportedHyperthreading = []string{
+	"masters",
+	"workers",
+	"all",
+	"none",
+}
+
 var supportedImageTypes = []string{
 	"minimal-iso",
 	"full-iso",
@@ -75,7 +89,17 @@ var supportedHostStatus = []string{
 	"discovering-unbound",
 }
 
-func valInList(value string, allowed_values []string) bool {
+var idPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Return true if value looks like an object id (a UUID). The service
+// rejects anything else with a validation error rather than a 404, so
+// there is no point asking it to look up a name as an id.
+func isID(value string) bool {
+	return idPattern.MatchString(value)
+}
+
+// ValInList returns true if value is one of allowed_values.
+func ValInList(value string, allowed_values []string) bool {
 	for _, this := range allowed_values {
 		if this == value {
 			return true
@@ -86,65 +110,78 @@ func valInList(value string, allowed_values []string) bool {
 }
 
 func ValidateHostStatus(status string) bool {
-	return valInList(status, supportedHostStatus)
+	return ValInList(status, supportedHostStatus)
 }
 
 func ValidateClusterStatus(status string) bool {
-	return valInList(status, supportedClusterStatus)
+	return ValInList(status, supportedClusterStatus)
 }
 
 func ValidateNetworkType(networkType string) bool {
-	return valInList(networkType, supportedNetworkTypes)
+	return ValInList(networkType, supportedNetworkTypes)
+}
+
+func ValidateHighAvailabilityMode(mode string) bool {
+	return ValInList(mode, supportedHighAvailabilityModes)
+}
+
+func ValidateHyperthreading(hyperthreading string) bool {
+	return ValInList(hyperthreading, supportedHyperthreading)
 }
 
 func ValidateImageType(imageType string) bool {
-	return valInList(imageType, supportedImageTypes)
+	return ValInList(imageType, supportedImageTypes)
 }
 
 func ValidateDownloadFile(filename string) bool {
-	return valInList(filename, supportedFiles)
+	return ValInList(filename, supportedFiles)
 }
 
 func (client *ApiClient) ListClusters() (ClusterList, error) {
+	return client.ListClustersCtx(context.Background())
+}
+
+func (client *ApiClient) ListClustersCtx(ctx context.Context) (ClusterList, error) {
 	var clusters ClusterList
 
-	req, err := client.NewRequest(
+	err := client.doRequest(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/clusters", client.ApiUrl),
-		nil,
+		nil, http.StatusOK,
+		"list clusters",
+		&clusters,
 	)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if err := json.Unmarshal(body, &clusters); err != nil {
-		return nil, err
-	}
 
 	return clusters, nil
 }
 
 // Find a cluster by id or name. First treat `name` as a cluster id
-// and attempt to fetch it directly. If that fails, get a list of
-// available clusters and look for the cluster name.
+// and attempt to fetch it directly. If there is no such cluster, get
+// a list of available clusters and look for the cluster name. Other
+// errors (e.g. ErrUnauthorized) are returned as they are.
 func (client *ApiClient) FindCluster(clusterid string) (*Cluster, error) {
-	detail, err := client.GetCluster(clusterid)
-	if err == nil {
-		return detail, nil
+	return client.FindClusterCtx(context.Background(), clusterid)
+}
+
+func (client *ApiClient) FindClusterCtx(ctx context.Context, clusterid string) (*Cluster, error) {
+	if isID(clusterid) {
+		detail, err := client.GetClusterCtx(ctx, clusterid)
+		if err == nil {
+			return detail, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
 	}
 
-	// It was either a name or a bad cluster id; in any case,
+	// It was either a name or an unknown cluster id; in any case,
 	// we get a list of clusters and then search for matching
 	// names.
-	clusters, err := client.ListClusters()
+	clusters, err := client.ListClustersCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -163,273 +200,172 @@ func (client *ApiClient) FindCluster(clusterid string) (*Cluster, error) {
 	}
 
 	// We found a cluster, let's try to get the cluster detail
-	detail, err = client.GetCluster(selected.ID)
-	if err != nil {
-		return nil, err
-	}
-
-	return detail, nil
+	return client.GetClusterCtx(ctx, selected.ID)
 }
 
 func (client *ApiClient) GetCluster(clusterid string) (*Cluster, error) {
+	return client.GetClusterCtx(context.Background(), clusterid)
+}
+
+func (client *ApiClient) GetClusterCtx(ctx context.Context, clusterid string) (*Cluster, error) {
 	var clusterDetail Cluster
 
-	req, err := client.NewRequest(
+	err := client.doRequest(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/clusters/%s", client.ApiUrl, clusterid),
-		nil,
+		nil, http.StatusOK,
+		fmt.Sprintf("get cluster %s", clusterid),
+		&clusterDetail,
 	)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 200 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return nil, fmt.Errorf(
-			"failed to get cluster %s: %s [%d]: %s",
-			clusterid,
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if err := json.Unmarshal(body, &clusterDetail); err != nil {
-		return nil, err
-	}
 
 	return &clusterDetail, nil
 }
 
 func (client *ApiClient) InstallCluster(clusterid string) error {
-	req, err := client.NewRequest(
+	return client.InstallClusterCtx(context.Background(), clusterid)
+}
+
+func (client *ApiClient) InstallClusterCtx(ctx context.Context, clusterid string) error {
+	return client.doRequest(
+		ctx,
 		"POST",
 		fmt.Sprintf("%s/clusters/%s/actions/install", client.ApiUrl, clusterid),
+		nil, http.StatusAccepted,
+		fmt.Sprintf("install cluster %s", clusterid),
 		nil,
 	)
-	if err != nil {
-		return err
-	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode != 202 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return fmt.Errorf(
-			"failed to install cluster %s: %s [%d]: %s",
-			clusterid,
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	return nil
-
 }
 
 func (client *ApiClient) CancelCluster(clusterid string) error {
-	req, err := client.NewRequest(
+	return client.CancelClusterCtx(context.Background(), clusterid)
+}
+
+func (client *ApiClient) CancelClusterCtx(ctx context.Context, clusterid string) error {
+	return client.doRequest(
+		ctx,
 		"POST",
 		fmt.Sprintf("%s/clusters/%s/actions/cancel", client.ApiUrl, clusterid),
+		nil, http.StatusAccepted,
+		fmt.Sprintf("cancel installation of cluster %s", clusterid),
 		nil,
 	)
-	if err != nil {
-		return err
-	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode != 202 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return fmt.Errorf(
-			"failed to install cluster %s: %s [%d]: %s",
-			clusterid,
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	return nil
-
 }
 
 func (client *ApiClient) ResetCluster(clusterid string) error {
-	req, err := client.NewRequest(
+	return client.ResetClusterCtx(context.Background(), clusterid)
+}
+
+func (client *ApiClient) ResetClusterCtx(ctx context.Context, clusterid string) error {
+	return client.doRequest(
+		ctx,
 		"POST",
 		fmt.Sprintf("%s/clusters/%s/actions/reset", client.ApiUrl, clusterid),
+		nil, http.StatusAccepted,
+		fmt.Sprintf("reset cluster %s", clusterid),
 		nil,
 	)
-	if err != nil {
-		return err
-	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode != 202 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return fmt.Errorf(
-			"failed to install cluster %s: %s [%d]: %s",
-			clusterid,
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	return nil
-
 }
 
 func (client *ApiClient) DeleteCluster(clusterid string) error {
-	req, err := client.NewRequest(
+	return client.DeleteClusterCtx(context.Background(), clusterid)
+}
+
+func (client *ApiClient) DeleteClusterCtx(ctx context.Context, clusterid string) error {
+	return client.doRequest(
+		ctx,
 		"DELETE",
 		fmt.Sprintf("%s/clusters/%s", client.ApiUrl, clusterid),
+		nil, http.StatusNoContent,
+		fmt.Sprintf("delete cluster %s", clusterid),
 		nil,
 	)
-	if err != nil {
-		return err
-	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode != 204 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return fmt.Errorf(
-			"failed to install cluster %s: %s [%d]: %s",
-			clusterid,
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	return nil
-
 }
 
 func (client *ApiClient) GetKubeconfig(clusterid string) ([]byte, error) {
-	req, err := client.NewRequest(
+	return client.GetKubeconfigCtx(context.Background(), clusterid)
+}
+
+func (client *ApiClient) GetKubeconfigCtx(ctx context.Context, clusterid string) ([]byte, error) {
+	var kubeconfig []byte
+
+	err := client.doRequest(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/clusters/%s/downloads/kubeconfig", client.ApiUrl, clusterid),
-		nil,
+		nil, http.StatusOK,
+		"fetch kubeconfig",
+		&kubeconfig,
 	)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 200 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return nil, fmt.Errorf(
-			"failed to fetch kubeconfig: %s [%d]: %s",
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
 
-	return body, nil
+	return kubeconfig, nil
 }
 
 func (client *ApiClient) GetFile(clusterid, filename string) ([]byte, error) {
-	req, err := client.NewRequest(
+	return client.GetFileCtx(context.Background(), clusterid, filename)
+}
+
+func (client *ApiClient) GetFileCtx(ctx context.Context, clusterid, filename string) ([]byte, error) {
+	var content []byte
+
+	err := client.doRequest(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/clusters/%s/downloads/files?file_name=%s",
-			client.ApiUrl, clusterid, filename),
-		nil,
+			client.ApiUrl, clusterid, url.QueryEscape(filename)),
+		nil, http.StatusOK,
+		fmt.Sprintf("fetch %s", filename),
+		&content,
 	)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 200 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return nil, fmt.Errorf(
-			"failed to fetch %s: %s [%d]: %s",
-			filename,
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
 
-	return body, nil
+	return content, nil
 }
 
 func (client *ApiClient) GetPullSecret() (*PullSecret, error) {
+	return client.GetPullSecretCtx(context.Background())
+}
+
+// GetPullSecretCtx fetches the pull secret of the current user from
+// the Red Hat accounts service. That only works (and we only want to
+// send our token there) when authenticating with Red Hat SSO.
+func (client *ApiClient) GetPullSecretCtx(ctx context.Context) (*PullSecret, error) {
 	var pullSecret PullSecret
 	var accessTokenUrl string = "https://api.openshift.com/api/accounts_mgmt/v1/access_token"
 
-	req, err := client.NewRequest(
+	if client.auth.Mode != AuthModeRHSSO {
+		return nil, fmt.Errorf("cannot get a pull secret from the api when auth mode is %s", client.auth.Mode)
+	}
+
+	err := client.doRequest(
+		ctx,
 		"POST",
 		accessTokenUrl,
-		nil,
+		nil, http.StatusOK,
+		"get pull secret",
+		&pullSecret,
 	)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 200 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return nil, fmt.Errorf(
-			"failed to get pull secret: %s [%d]: %s",
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if err := json.Unmarshal(body, &pullSecret); err != nil {
-		return nil, err
-	}
 
 	return &pullSecret, nil
 }
 
 func (client *ApiClient) CreateDiscoveryImage(
+	clusterid string, imageType string, sshPublicKey string) (*Cluster, error) {
+	return client.CreateDiscoveryImageCtx(context.Background(), clusterid, imageType, sshPublicKey)
+}
+
+func (client *ApiClient) CreateDiscoveryImageCtx(ctx context.Context,
 	clusterid string, imageType string, sshPublicKey string) (*Cluster, error) {
 	var cluster Cluster
 
@@ -442,125 +378,73 @@ func (client *ApiClient) CreateDiscoveryImage(
 		return nil, err
 	}
 
-	req, err := client.NewRequest(
+	err = client.doRequest(
+		ctx,
 		"POST",
 		fmt.Sprintf(
 			"%s/clusters/%s/downloads/image",
 			client.ApiUrl,
 			clusterid),
-		bytes.NewBuffer(createParamsJson),
+		createParamsJson, http.StatusCreated,
+		fmt.Sprintf("create discovery image for cluster %s", clusterid),
+		&cluster,
 	)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 201 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return nil, fmt.Errorf(
-			"failed to create cluster: %s [%d]: %s",
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := json.Unmarshal(body, &cluster); err != nil {
-		return nil, err
-	}
 
 	return &cluster, nil
 }
 
 func (client *ApiClient) CreateCluster(cluster *ClusterCreateParams) (*Cluster, error) {
+	return client.CreateClusterCtx(context.Background(), cluster)
+}
+
+func (client *ApiClient) CreateClusterCtx(ctx context.Context, cluster *ClusterCreateParams) (*Cluster, error) {
 	createParamsJson, err := cluster.ToJSON()
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := client.NewRequest(
+	var detail Cluster
+	err = client.doRequest(
+		ctx,
 		"POST",
 		fmt.Sprintf("%s/clusters", client.ApiUrl),
-		bytes.NewBuffer(createParamsJson),
+		createParamsJson, http.StatusCreated,
+		fmt.Sprintf("create cluster %s", cluster.Name),
+		&detail,
 	)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 201 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return nil, fmt.Errorf(
-			"failed to create cluster: %s [%d]: %s",
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var detail Cluster
-	if err := json.Unmarshal(body, &detail); err != nil {
-		return nil, err
-	}
 
 	return &detail, nil
 }
 
 func (client *ApiClient) PatchCluster(clusterid string, patch JsonObject) (*Cluster, error) {
+	return client.PatchClusterCtx(context.Background(), clusterid, patch)
+}
+
+func (client *ApiClient) PatchClusterCtx(ctx context.Context, clusterid string, patch JsonObject) (*Cluster, error) {
 	patchJson, err := patch.ToJSON()
-	log.Debugf("patching cluster %s with: %s", clusterid, string(patchJson))
 	if err != nil {
 		return nil, err
 	}
+	log.Debugf("patching cluster %s with: %s", clusterid, string(patchJson))
 
-	req, err := client.NewRequest(
+	var detail Cluster
+	err = client.doRequest(
+		ctx,
 		"PATCH",
 		fmt.Sprintf("%s/clusters/%s", client.ApiUrl, clusterid),
-		bytes.NewBuffer(patchJson),
+		patchJson, http.StatusCreated,
+		fmt.Sprintf("patch cluster %s", clusterid),
+		&detail,
 	)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 201 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return nil, fmt.Errorf(
-			"failed to patch cluster: %s [%d]: %s",
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var detail Cluster
-	if err := json.Unmarshal(body, &detail); err != nil {
-		return nil, err
-	}
 
 	return &detail, nil
 }
@@ -591,3 +475,21 @@ func (patch *ClusterNetworkPatch) ToJSON() ([]byte, error) {
 
 	return patchJson, nil
 }
+
+func (patch ClusterPatch) ToJSON() ([]byte, error) {
+	patchJson, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return patchJson, nil
+}
+
+func (params *ClusterUpdateParams) ToJSON() ([]byte, error) {
+	paramsJson, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return paramsJson, nil
+}
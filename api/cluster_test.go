@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testClusterID = "0b1c1ef4-43f9-4d6c-a6c4-2b0b1e0c3a10"
+	otherID       = "7d1f6ca2-9b0e-4d55-8c1e-4f1e0a2b3c4d"
+)
+
+// Start a server with one cluster, named lab. Requests for the id
+// in status are answered with that status code. The requests the
+// server sees are recorded in requests.
+func newFindTestServer(t *testing.T, status map[string]int, requests *[]string) *ApiClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path)
+
+		switch r.URL.Path {
+		case "/clusters":
+			fmt.Fprintf(w, `[{"id": %q, "name": "lab"}]`, testClusterID)
+		case "/clusters/" + testClusterID:
+			fmt.Fprintf(w, `{"id": %q, "name": "lab"}`, testClusterID)
+		default:
+			code := http.StatusNotFound
+			for id, c := range status {
+				if r.URL.Path == "/clusters/"+id {
+					code = c
+				}
+			}
+			w.WriteHeader(code)
+			fmt.Fprint(w, `{"reason": "nope"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewApiClientWithAuth(server.URL, AuthConfig{Mode: AuthModeNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Retry.MaxAttempts = 1
+
+	return client
+}
+
+func TestFindCluster(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		find     string
+		status   int
+		want     error
+		requests int
+	}{
+		{"by id", testClusterID, 0, nil, 1},
+		{"by name", "lab", 0, nil, 2},
+		{"unknown id", otherID, http.StatusNotFound, ErrNotFound, 2},
+		{"unauthorized", otherID, http.StatusUnauthorized, ErrUnauthorized, 1},
+		{"server error", otherID, http.StatusInternalServerError, ErrInternalServerError, 1},
+	} {
+		var requests []string
+		client := newFindTestServer(t, map[string]int{otherID: tc.status}, &requests)
+
+		cluster, err := client.FindCluster(tc.find)
+		switch {
+		case tc.want == nil && err != nil:
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		case tc.want == nil && cluster.ID != testClusterID:
+			t.Errorf("%s: got cluster %s", tc.name, cluster.ID)
+		case tc.want == ErrNotFound && err == nil:
+			// An unknown id falls back to searching by name, which
+			// fails without an APIError.
+			t.Errorf("%s: expected an error", tc.name)
+		case tc.want != nil && tc.want != ErrNotFound && !errors.Is(err, tc.want):
+			t.Errorf("%s: got error %v, want %v", tc.name, err, tc.want)
+		}
+
+		if len(requests) != tc.requests {
+			t.Errorf("%s: got requests %v, want %d", tc.name, requests, tc.requests)
+		}
+	}
+}
+
+func TestGetPullSecretAuthMode(t *testing.T) {
+	for _, auth := range []AuthConfig{
+		{Mode: AuthModeNone},
+		{Mode: AuthModeStaticBearer, Token: "lab-token"},
+		{Mode: AuthModeCustomOIDC, OfflineToken: "offline", TokenUrl: "http://127.0.0.1:1/token"},
+	} {
+		client, err := NewApiClientWithAuth("http://127.0.0.1:1", auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// This must fail before sending our token anywhere.
+		if _, err := client.GetPullSecret(); err == nil || !strings.Contains(err.Error(), string(auth.Mode)) {
+			t.Errorf("%s: got error %v", auth.Mode, err)
+		}
+	}
+}
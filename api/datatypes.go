@@ -22,6 +22,10 @@ type (
 
 	ClusterList []Cluster
 
+	// A set of cluster attributes to change, for use with
+	// PatchCluster. The keys are api field names.
+	ClusterPatch map[string]interface{}
+
 	ClusterNetworkPatch struct {
 		ApiVip            string `json:"api_vip"`
 		IngressVip        string `json:"ingress_vip"`
@@ -29,11 +33,13 @@ type (
 	}
 
 	Cluster struct {
+		AdditionalNtpSource        string               `json:"additional_ntp_source"`
 		AmsSubscriptionID          string               `json:"ams_subscription_id"`
 		ApiVip                     string               `json:"api_vip"`
 		BaseDNSDomain              string               `json:"base_dns_domain"`
 		ClusterNetworkCidr         string               `json:"cluster_network_cidr"`
 		ClusterNetworkHostPrefix   int                  `json:"cluster_network_host_prefix"`
+		ClusterNetworks            []ClusterNetwork     `json:"cluster_networks,omitempty"`
 		ConnectivityMajorityGroups string               `json:"connectivity_majority_groups"`
 		ControllerLogsCollectedAt  time.Time            `json:"controller_logs_collected_at"`
 		ControllerLogsStartedAt    time.Time            `json:"controller_logs_started_at"`
@@ -45,6 +51,8 @@ type (
 		HostNetworks               []HostNetworks       `json:"host_networks"`
 		Hosts                      []Host               `json:"hosts"`
 		Href                       string               `json:"href"`
+		HttpProxy                  string               `json:"http_proxy"`
+		HttpsProxy                 string               `json:"https_proxy"`
 		Hyperthreading             string               `json:"hyperthreading"`
 		ID                         string               `json:"id"`
 		ImageInfo                  ImageInfo            `json:"image_info"`
@@ -53,9 +61,11 @@ type (
 		InstallStartedAt           time.Time            `json:"install_started_at"`
 		Kind                       string               `json:"kind"`
 		MachineNetworkCidr         string               `json:"machine_network_cidr"`
+		MachineNetworks            []MachineNetwork     `json:"machine_networks,omitempty"`
 		MonitoredOperators         []MonitoredOperators `json:"monitored_operators"`
 		Name                       string               `json:"name"`
 		NetworkType                string               `json:"network_type"`
+		NoProxy                    string               `json:"no_proxy"`
 		OcpReleaseImage            string               `json:"ocp_release_image"`
 		OpenshiftVersion           string               `json:"openshift_version"`
 		OrgID                      string               `json:"org_id"`
@@ -64,6 +74,7 @@ type (
 		PullSecretSet              bool                 `json:"pull_secret_set"`
 		SchedulableMasters         bool                 `json:"schedulable_masters"`
 		ServiceNetworkCidr         string               `json:"service_network_cidr"`
+		ServiceNetworks            []ServiceNetwork     `json:"service_networks,omitempty"`
 		SshPublicKey               string               `json:"ssh_public_key"`
 		Status                     string               `json:"status"`
 		StatusInfo                 string               `json:"status_info"`
@@ -75,6 +86,21 @@ type (
 		ValidationsInfo            string               `json:"validations_info"`
 		VipDhcpAllocation          bool                 `json:"vip_dhcp_allocation"`
 	}
+	// The list-based network fields are used for dual-stack clusters,
+	// which have one IPv4 and one IPv6 network of each type.
+	ClusterNetwork struct {
+		Cidr       string `yaml:"cidr" json:"cidr"`
+		HostPrefix int    `yaml:"host_prefix,omitempty" json:"host_prefix,omitempty"`
+		ClusterID  string `yaml:"cluster_id,omitempty" json:"cluster_id,omitempty"`
+	}
+	ServiceNetwork struct {
+		Cidr      string `yaml:"cidr" json:"cidr"`
+		ClusterID string `yaml:"cluster_id,omitempty" json:"cluster_id,omitempty"`
+	}
+	MachineNetwork struct {
+		Cidr      string `yaml:"cidr" json:"cidr"`
+		ClusterID string `yaml:"cluster_id,omitempty" json:"cluster_id,omitempty"`
+	}
 	HostNetworks struct {
 		Cidr    string   `json:"cidr"`
 		HostIds []string `json:"host_ids"`
@@ -183,17 +209,17 @@ type (
 		VendorID string `json:"vendor_id"`
 	}
 	Interfaces struct {
-		Biosdevname   string        `json:"biosdevname"`
-		Flags         []string      `json:"flags"`
-		HasCarrier    bool          `json:"has_carrier,omitempty"`
-		Ipv4Addresses []string      `json:"ipv4_addresses"`
-		Ipv6Addresses []interface{} `json:"ipv6_addresses"`
-		MacAddress    string        `json:"mac_address"`
-		Mtu           int           `json:"mtu"`
-		Name          string        `json:"name"`
-		Product       string        `json:"product"`
-		SpeedMbps     int           `json:"speed_mbps,omitempty"`
-		Vendor        string        `json:"vendor"`
+		Biosdevname   string   `json:"biosdevname"`
+		Flags         []string `json:"flags"`
+		HasCarrier    bool     `json:"has_carrier,omitempty"`
+		Ipv4Addresses []string `json:"ipv4_addresses"`
+		Ipv6Addresses []string `json:"ipv6_addresses"`
+		MacAddress    string   `json:"mac_address"`
+		Mtu           int      `json:"mtu"`
+		Name          string   `json:"name"`
+		Product       string   `json:"product"`
+		SpeedMbps     int      `json:"speed_mbps,omitempty"`
+		Vendor        string   `json:"vendor"`
 	}
 	Memory struct {
 		PhysicalBytes int64 `json:"physical_bytes"`
@@ -220,6 +246,91 @@ type (
 		HostName string `json:"hostname"`
 	}
 
+	// Cluster attributes to change with PatchCluster. Only fields
+	// that are set are sent to the api.
+	ClusterUpdateParams struct {
+		Name                     *string           `json:"name,omitempty"`
+		BaseDnsDomain            *string           `json:"base_dns_domain,omitempty"`
+		ClusterNetworkCidr       *string           `json:"cluster_network_cidr,omitempty"`
+		ClusterNetworkHostPrefix *int              `json:"cluster_network_host_prefix,omitempty"`
+		ServiceNetworkCidr       *string           `json:"service_network_cidr,omitempty"`
+		MachineNetworkCidr       *string           `json:"machine_network_cidr,omitempty"`
+		ClusterNetworks          []ClusterNetwork  `json:"cluster_networks,omitempty"`
+		ServiceNetworks          []ServiceNetwork  `json:"service_networks,omitempty"`
+		MachineNetworks          []MachineNetwork  `json:"machine_networks,omitempty"`
+		ApiVip                   *string           `json:"api_vip,omitempty"`
+		IngressVip               *string           `json:"ingress_vip,omitempty"`
+		VipDhcpAllocation        *bool             `json:"vip_dhcp_allocation,omitempty"`
+		HttpProxy                *string           `json:"http_proxy,omitempty"`
+		HttpsProxy               *string           `json:"https_proxy,omitempty"`
+		NoProxy                  *string           `json:"no_proxy,omitempty"`
+		AdditionalNtpSource      *string           `json:"additional_ntp_source,omitempty"`
+		SshPublicKey             *string           `json:"ssh_public_key,omitempty"`
+		PullSecret               *string           `json:"pull_secret,omitempty"`
+		Hyperthreading           *string           `json:"hyperthreading,omitempty"`
+		NetworkType              *string           `json:"network_type,omitempty"`
+		UserManagedNetworking    *bool             `json:"user_managed_networking,omitempty"`
+		SchedulableMasters       *bool             `json:"schedulable_masters,omitempty"`
+		HostsRoles               []HostRole        `json:"hosts_roles,omitempty"`
+		HostsNames               []HostName        `json:"hosts_names,omitempty"`
+		DisksSelectedConfig      []HostDisksConfig `json:"disks_selected_config,omitempty"`
+	}
+
+	EventList []Event
+
+	Event struct {
+		ClusterID  string    `json:"cluster_id"`
+		HostID     string    `json:"host_id,omitempty"`
+		InfraEnvID string    `json:"infra_env_id,omitempty"`
+		Name       string    `json:"name,omitempty"`
+		Category   string    `json:"category,omitempty"`
+		Severity   string    `json:"severity"`
+		EventTime  time.Time `json:"event_time"`
+		Message    string    `json:"message"`
+		RequestID  string    `json:"request_id,omitempty"`
+		Props      string    `json:"props,omitempty"`
+	}
+
+	// ValidationsInfo is the decoded form of the validations_info
+	// field of clusters and hosts: validation results grouped by
+	// category (e.g. "network", "hardware").
+	ValidationsInfo map[string][]Validation
+
+	Validation struct {
+		ID       string `json:"id"`
+		Status   string `json:"status"`
+		Message  string `json:"message"`
+		Category string `json:"category,omitempty"`
+	}
+
+	HostRole struct {
+		ID   string `json:"id"`
+		Role string `json:"role"`
+	}
+
+	DiskConfig struct {
+		ID   string `json:"id"`
+		Role string `json:"role"`
+	}
+
+	HostDisksConfig struct {
+		ID          string       `json:"id"`
+		DisksConfig []DiskConfig `json:"disks_config"`
+	}
+
+	Manifest struct {
+		FileName string `json:"file_name"`
+		Folder   string `json:"folder"`
+	}
+
+	CreateManifestParams struct {
+		FileName string `json:"file_name"`
+		Folder   string `json:"folder"`
+
+		// Base64 encoded manifest content
+		Content string `json:"content"`
+	}
+
 	ClusterInstallParams struct {
 		Name                 string `json:"name"`
 		OpenshiftVersion     string `json:"openshift_version"`
@@ -230,28 +341,31 @@ type (
 
 	ClusterCreateParams struct {
 		// Required
-		Name             string `json:"name"`
-		OpenshiftVersion string `json:"openshift_version"`
-		PullSecret       string `json:"pull_secret"`
+		Name             string `yaml:"name" json:"name"`
+		OpenshiftVersion string `yaml:"openshift_version" json:"openshift_version"`
+		PullSecret       string `yaml:"pull_secret" json:"pull_secret"`
 
 		// Optional
-		HighAvailabilityMode     string `json:"high_availability_mode,omitempty"`
-		OcpReleaseImage          string `json:"ocp_release_image,omitempty"`
-		BaseDnsDomain            string `json:"base_dns_domain,omitempty"`
-		ClusterNetworkCidr       string `json:"cluster_network_cidr,omitempty"`
-		ClusterNetworkHostPrefix int    `json:"cluster_network_host_prefix,omitempty"`
-		ServiceNetworkCidr       string `json:"service_network_cidr,omitempty"`
-		IngressVip               string `json:"ingress_vip,omitempty"`
-		SshPublicKey             string `json:"ssh_public_key,omitempty"`
-		VipDhcpAllocation        bool   `json:"vip_dhcp_allocation,omitempty"`
-		HttpProxy                string `json:"http_proxy,omitempty"`
-		HttpsProxy               string `json:"https_proxy,omitempty"`
-		NoProxy                  string `json:"no_proxy,omitempty"`
-		UserManagedNetworking    bool   `json:"user_managed_networking,omitempty"`
-		AdditionalNtpSource      string `json:"additional_ntp_source,omitempty"`
-		Hyperthreading           string `json:"hyperthreading,omitempty"`
-		NetworkType              string `json:"network_type,omitempty"`
-		SchedulableMasters       bool   `json:"schedulable_masters,omitempty"`
+		HighAvailabilityMode     string           `yaml:"high_availability_mode,omitempty" json:"high_availability_mode,omitempty"`
+		OcpReleaseImage          string           `yaml:"ocp_release_image,omitempty" json:"ocp_release_image,omitempty"`
+		BaseDnsDomain            string           `yaml:"base_dns_domain,omitempty" json:"base_dns_domain,omitempty"`
+		ClusterNetworkCidr       string           `yaml:"cluster_network_cidr,omitempty" json:"cluster_network_cidr,omitempty"`
+		ClusterNetworkHostPrefix int              `yaml:"cluster_network_host_prefix,omitempty" json:"cluster_network_host_prefix,omitempty"`
+		ServiceNetworkCidr       string           `yaml:"service_network_cidr,omitempty" json:"service_network_cidr,omitempty"`
+		ClusterNetworks          []ClusterNetwork `yaml:"cluster_networks,omitempty" json:"cluster_networks,omitempty"`
+		ServiceNetworks          []ServiceNetwork `yaml:"service_networks,omitempty" json:"service_networks,omitempty"`
+		MachineNetworks          []MachineNetwork `yaml:"machine_networks,omitempty" json:"machine_networks,omitempty"`
+		IngressVip               string           `yaml:"ingress_vip,omitempty" json:"ingress_vip,omitempty"`
+		SshPublicKey             string           `yaml:"ssh_public_key,omitempty" json:"ssh_public_key,omitempty"`
+		VipDhcpAllocation        bool             `yaml:"vip_dhcp_allocation,omitempty" json:"vip_dhcp_allocation,omitempty"`
+		HttpProxy                string           `yaml:"http_proxy,omitempty" json:"http_proxy,omitempty"`
+		HttpsProxy               string           `yaml:"https_proxy,omitempty" json:"https_proxy,omitempty"`
+		NoProxy                  string           `yaml:"no_proxy,omitempty" json:"no_proxy,omitempty"`
+		UserManagedNetworking    bool             `yaml:"user_managed_networking,omitempty" json:"user_managed_networking,omitempty"`
+		AdditionalNtpSource      string           `yaml:"additional_ntp_source,omitempty" json:"additional_ntp_source,omitempty"`
+		Hyperthreading           string           `yaml:"hyperthreading,omitempty" json:"hyperthreading,omitempty"`
+		NetworkType              string           `yaml:"network_type,omitempty" json:"network_type,omitempty"`
+		SchedulableMasters       bool             `yaml:"schedulable_masters,omitempty" json:"schedulable_masters,omitempty"`
 	}
 
 	PullSecret struct {
@@ -268,6 +382,67 @@ type (
 		SshPublicKey string `json:"ssh_public_key"`
 	}
 
+	Proxy struct {
+		HttpProxy  string `json:"http_proxy,omitempty"`
+		HttpsProxy string `json:"https_proxy,omitempty"`
+		NoProxy    string `json:"no_proxy,omitempty"`
+	}
+
+	InfraEnvList []InfraEnv
+
+	InfraEnv struct {
+		AdditionalNtpSources string    `json:"additional_ntp_sources"`
+		ClusterID            string    `json:"cluster_id"`
+		CpuArchitecture      string    `json:"cpu_architecture"`
+		CreatedAt            time.Time `json:"created_at"`
+		DownloadUrl          string    `json:"download_url"`
+		EmailDomain          string    `json:"email_domain"`
+		ExpiresAt            time.Time `json:"expires_at"`
+		GeneratorVersion     string    `json:"generator_version"`
+		Href                 string    `json:"href"`
+		ID                   string    `json:"id"`
+		Kind                 string    `json:"kind"`
+		Name                 string    `json:"name"`
+		OpenshiftVersion     string    `json:"openshift_version"`
+		OrgID                string    `json:"org_id"`
+		Proxy                *Proxy    `json:"proxy,omitempty"`
+		PullSecretSet        bool      `json:"pull_secret_set"`
+		SizeBytes            int       `json:"size_bytes"`
+		SshAuthorizedKey     string    `json:"ssh_authorized_key"`
+		StaticNetworkConfig  string    `json:"static_network_config"`
+		Type                 string    `json:"type"`
+		UpdatedAt            time.Time `json:"updated_at"`
+		UserName             string    `json:"user_name"`
+	}
+
+	InfraEnvCreateParams struct {
+		// Required
+		Name       string `json:"name"`
+		PullSecret string `json:"pull_secret"`
+
+		// Optional
+		ClusterID            string `json:"cluster_id,omitempty"`
+		OpenshiftVersion     string `json:"openshift_version,omitempty"`
+		CpuArchitecture      string `json:"cpu_architecture,omitempty"`
+		ImageType            string `json:"image_type,omitempty"`
+		SshAuthorizedKey     string `json:"ssh_authorized_key,omitempty"`
+		AdditionalNtpSources string `json:"additional_ntp_sources,omitempty"`
+		Proxy                *Proxy `json:"proxy,omitempty"`
+	}
+
+	InfraEnvUpdateParams struct {
+		ImageType            string `json:"image_type,omitempty"`
+		SshAuthorizedKey     string `json:"ssh_authorized_key,omitempty"`
+		AdditionalNtpSources string `json:"additional_ntp_sources,omitempty"`
+		PullSecret           string `json:"pull_secret,omitempty"`
+		Proxy                *Proxy `json:"proxy,omitempty"`
+	}
+
+	PresignedUrl struct {
+		Url       string    `json:"url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
 	ImageInfo struct {
 		SshPublicKey        string `json:"ssh_public_key"`
 		SizeBytes           int    `json:"size_bytes"`
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DiskPolicy describes how to pick an installation disk from a host's
+// inventory when we don't want to name the disk explicitly.
+type DiskPolicy struct {
+	// Only consider disks of this type (e.g. "SSD" or "HDD"). Empty
+	// means any type.
+	DriveType string
+
+	// Only consider disks at least this large (in bytes). Zero means
+	// no minimum.
+	MinSize int64
+
+	// Only consider disks at most this large (in bytes). Zero means
+	// no maximum.
+	MaxSize int64
+
+	// Pick the "smallest" or "largest" matching disk.
+	Select string
+}
+
+var supportedDiskSelections = []string{
+	"smallest",
+	"largest",
+}
+
+func ValidateDiskSelection(selection string) bool {
+	return ValInList(selection, supportedDiskSelections)
+}
+
+// Validate checks that the policy makes sense.
+func (policy *DiskPolicy) Validate() error {
+	if !ValidateDiskSelection(policy.Select) {
+		return fmt.Errorf("invalid disk selection %q: must be one of %v",
+			policy.Select, supportedDiskSelections)
+	}
+
+	if policy.MinSize < 0 || policy.MaxSize < 0 {
+		return fmt.Errorf("disk sizes cannot be negative")
+	}
+
+	if policy.MaxSize != 0 && policy.MinSize > policy.MaxSize {
+		return fmt.Errorf("minimum disk size is larger than maximum disk size")
+	}
+
+	return nil
+}
+
+// SelectDisk returns the disk in the inventory that best matches the
+// policy. The installation media is never selected. Ties are broken
+// by disk name so that the result is stable.
+func (policy *DiskPolicy) SelectDisk(inventory *HostInventory) (*Disks, error) {
+	var candidates []Disks
+
+	for _, disk := range inventory.Disks {
+		if disk.IsInstallationMedia || disk.SizeBytes == 0 {
+			continue
+		}
+		if policy.DriveType != "" && !strings.EqualFold(disk.DriveType, policy.DriveType) {
+			continue
+		}
+		if disk.SizeBytes < policy.MinSize {
+			continue
+		}
+		if policy.MaxSize != 0 && disk.SizeBytes > policy.MaxSize {
+			continue
+		}
+
+		candidates = append(candidates, disk)
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no disk matches the selection policy")
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].SizeBytes != candidates[j].SizeBytes {
+			if policy.Select == "largest" {
+				return candidates[i].SizeBytes > candidates[j].SizeBytes
+			}
+			return candidates[i].SizeBytes < candidates[j].SizeBytes
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	return &candidates[0], nil
+}
+
+// Size suffixes accepted by ParseSize. Decimal suffixes are powers
+// of 1000 and binary ("iB") suffixes powers of 1024, as in the
+// disk sizes reported by the api and by tools like lsblk.
+var sizeSuffixes = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"KIB", 1 << 10},
+	{"MIB", 1 << 20},
+	{"GIB", 1 << 30},
+	{"TIB", 1 << 40},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"TB", 1e12},
+	{"K", 1e3},
+	{"M", 1e6},
+	{"G", 1e9},
+	{"T", 1e12},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as "120G" or "1.5TiB" into bytes.
+// "K", "M", "G" and "T" (with or without a trailing "B") are powers
+// of 1000; "KiB", "MiB", "GiB" and "TiB" are powers of 1024.
+func ParseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+
+	multiplier := int64(1)
+	for _, suffix := range sizeSuffixes {
+		if strings.HasSuffix(s, suffix.suffix) {
+			s = strings.TrimSuffix(s, suffix.suffix)
+			multiplier = suffix.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	return int64(number * float64(multiplier)), nil
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  int64
+		ok    bool
+	}{
+		{"512", 512, true},
+		{"512B", 512, true},
+		{"120G", 120e9, true},
+		{"120g", 120e9, true},
+		{"120GB", 120e9, true},
+		{"1.5T", 1.5e12, true},
+		{"2TB", 2e12, true},
+		{"4K", 4e3, true},
+		{"4KB", 4e3, true},
+		{"10M", 10e6, true},
+		{"4KiB", 4 << 10, true},
+		{"10MiB", 10 << 20, true},
+		{"64GiB", 64 << 30, true},
+		{"64gib", 64 << 30, true},
+		{"1.5TiB", 3 << 39, true},
+		{" 120 G ", 120e9, true},
+		{"", 0, false},
+		{"G", 0, false},
+		{"-1G", 0, false},
+		{"120X", 0, false},
+		{"120Gi", 0, false},
+		{"GiB", 0, false},
+	} {
+		got, err := ParseSize(tc.value)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.value)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+			continue
+		}
+
+		if got != tc.want {
+			t.Errorf("%q: got %d, want %d", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestDiskPolicyValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy DiskPolicy
+		ok     bool
+	}{
+		{"smallest", DiskPolicy{Select: "smallest"}, true},
+		{"largest with sizes", DiskPolicy{Select: "largest", MinSize: 1e9, MaxSize: 2e9}, true},
+		{"no selection", DiskPolicy{}, false},
+		{"bad selection", DiskPolicy{Select: "fastest"}, false},
+		{"negative size", DiskPolicy{Select: "smallest", MinSize: -1}, false},
+		{"min above max", DiskPolicy{Select: "smallest", MinSize: 2e9, MaxSize: 1e9}, false},
+	} {
+		err := tc.policy.Validate()
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestSelectDisk(t *testing.T) {
+	inventory := HostInventory{
+		Disks: []Disks{
+			{Name: "sr0", DriveType: "ODD", SizeBytes: 1e9, IsInstallationMedia: true},
+			{Name: "sda", DriveType: "HDD", SizeBytes: 100e9},
+			{Name: "sdb", DriveType: "SSD", SizeBytes: 200e9},
+			{Name: "sdc", DriveType: "SSD", SizeBytes: 200e9},
+			{Name: "sdd", DriveType: "SSD", SizeBytes: 960e9},
+			{Name: "loop0", DriveType: "HDD"},
+		},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		policy DiskPolicy
+		want   string
+	}{
+		{"smallest", DiskPolicy{Select: "smallest"}, "sda"},
+		{"largest", DiskPolicy{Select: "largest"}, "sdd"},
+		{"smallest ssd", DiskPolicy{Select: "smallest", DriveType: "ssd"}, "sdb"},
+		{"largest under max", DiskPolicy{Select: "largest", MaxSize: 500e9}, "sdb"},
+		{"smallest over min", DiskPolicy{Select: "smallest", MinSize: 150e9}, "sdb"},
+		{"nothing matches", DiskPolicy{Select: "smallest", DriveType: "NVME"}, ""},
+		{"too large", DiskPolicy{Select: "smallest", MinSize: 1e12}, ""},
+	} {
+		disk, err := tc.policy.SelectDisk(&inventory)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", tc.name, disk.Name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+
+		if disk.Name != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, disk.Name, tc.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type (
+	// APIError is returned when the assisted-service responds to a
+	// request with an unexpected status code. The Code, Reason and
+	// Href fields are decoded from the error payload returned by the
+	// service, if there is one.
+	APIError struct {
+		StatusCode int    `json:"-"`
+		Operation  string `json:"-"`
+		ID         int    `json:"id"`
+		Kind       string `json:"kind"`
+		Code       string `json:"code"`
+		Reason     string `json:"reason"`
+		Href       string `json:"href"`
+	}
+)
+
+// These can be used with errors.Is to check for specific failures, e.g.
+//
+//	if errors.Is(err, api.ErrNotFound) { ... }
+var (
+	ErrBadRequest          = errors.New("bad request")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
+	ErrNotFound            = errors.New("not found")
+	ErrMethodNotAllowed    = errors.New("method not allowed")
+	ErrConflict            = errors.New("conflict")
+	ErrServiceUnavailable  = errors.New("service unavailable")
+	ErrInternalServerError = errors.New("internal server error")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:          ErrBadRequest,
+	http.StatusUnauthorized:        ErrUnauthorized,
+	http.StatusForbidden:           ErrForbidden,
+	http.StatusNotFound:            ErrNotFound,
+	http.StatusMethodNotAllowed:    ErrMethodNotAllowed,
+	http.StatusConflict:            ErrConflict,
+	http.StatusServiceUnavailable:  ErrServiceUnavailable,
+	http.StatusInternalServerError: ErrInternalServerError,
+}
+
+// Build an APIError from an error response. If the body isn't a
+// valid error payload we use the body itself as the reason.
+func newAPIError(operation string, statusCode int, body []byte) *APIError {
+	apierr := APIError{}
+
+	if err := json.Unmarshal(body, &apierr); err != nil || apierr.Reason == "" {
+		apierr.Reason = string(body)
+	}
+
+	apierr.StatusCode = statusCode
+	apierr.Operation = operation
+
+	return &apierr
+}
+
+func (apierr *APIError) Error() string {
+	msg := fmt.Sprintf("%s [%d]", http.StatusText(apierr.StatusCode), apierr.StatusCode)
+	if apierr.Operation != "" {
+		msg = fmt.Sprintf("failed to %s: %s", apierr.Operation, msg)
+	}
+	if apierr.Reason != "" {
+		msg = fmt.Sprintf("%s: %s", msg, apierr.Reason)
+	}
+
+	return msg
+}
+
+// Is reports whether target is the sentinel error corresponding to
+// this error's status code.
+func (apierr *APIError) Is(target error) bool {
+	return statusErrors[apierr.StatusCode] == target
+}
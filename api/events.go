@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Event severities, from least to most severe.
+var supportedEventSeverities = []string{
+	"info",
+	"warning",
+	"error",
+	"critical",
+}
+
+func ValidateEventSeverity(severity string) bool {
+	return ValInList(severity, supportedEventSeverities)
+}
+
+// SeverityLevel returns the position of a severity in the list of
+// supported severities (higher is more severe), or -1 if the
+// severity is unknown.
+func SeverityLevel(severity string) int {
+	for i, this := range supportedEventSeverities {
+		if this == severity {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (client *ApiClient) ListEvents(clusterid, hostid string, since time.Time) (EventList, error) {
+	return client.ListEventsCtx(context.Background(), clusterid, hostid, since)
+}
+
+// ListEventsCtx returns the events for a cluster (or, if hostid is
+// set, for one host in the cluster). If since is set, only events at
+// or after that time are returned.
+func (client *ApiClient) ListEventsCtx(ctx context.Context, clusterid, hostid string, since time.Time) (EventList, error) {
+	var events EventList
+
+	query := url.Values{}
+	if hostid != "" {
+		query.Set("host_id", hostid)
+	}
+
+	eventsUrl := fmt.Sprintf("%s/clusters/%s/events", client.ApiUrl, clusterid)
+	if len(query) > 0 {
+		eventsUrl = fmt.Sprintf("%s?%s", eventsUrl, query.Encode())
+	}
+
+	err := client.doRequest(
+		ctx,
+		"GET",
+		eventsUrl,
+		nil, http.StatusOK,
+		fmt.Sprintf("list events for cluster %s", clusterid),
+		&events,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if since.IsZero() {
+		return events, nil
+	}
+
+	var selected EventList
+	for _, event := range events {
+		if !event.EventTime.Before(since) {
+			selected = append(selected, event)
+		}
+	}
+
+	return selected, nil
+}
@@ -1,11 +1,12 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"strings"
 )
 
 func (host *Host) GetInventory() (*HostInventory, error) {
@@ -18,40 +19,36 @@ func (host *Host) GetInventory() (*HostInventory, error) {
 }
 
 func (client *ApiClient) DeleteHost(clusterid string, hostid string) error {
-	req, err := client.NewRequest(
+	return client.DeleteHostCtx(context.Background(), clusterid, hostid)
+}
+
+func (client *ApiClient) DeleteHostCtx(ctx context.Context, clusterid string, hostid string) error {
+	return client.doRequest(
+		ctx,
 		"DELETE",
 		fmt.Sprintf("%s/clusters/%s/hosts/%s", client.ApiUrl, clusterid, hostid),
+		nil, http.StatusNoContent,
+		fmt.Sprintf("delete host %s", hostid),
 		nil,
 	)
-	if err != nil {
-		return err
-	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode != 204 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
-		}
-		return fmt.Errorf(
-			"failed to delete host %s: %s [%d]: %s",
-			hostid,
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
-	}
-
-	return nil
 }
 
 func (client *ApiClient) FindHost(clusterid string, hostid string) (*Host, error) {
-	host, err := client.GetHost(clusterid, hostid)
-	if err == nil {
-		return host, nil
+	return client.FindHostCtx(context.Background(), clusterid, hostid)
+}
+
+func (client *ApiClient) FindHostCtx(ctx context.Context, clusterid string, hostid string) (*Host, error) {
+	if isID(clusterid) && isID(hostid) {
+		host, err := client.GetHostCtx(ctx, clusterid, hostid)
+		if err == nil {
+			return host, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
 	}
 
-	cluster, err := client.FindCluster(clusterid)
+	cluster, err := client.FindClusterCtx(ctx, clusterid)
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +63,10 @@ func (client *ApiClient) FindHost(clusterid string, hostid string) (*Host, error
 }
 
 func (client *ApiClient) SetHostnames(clusterid string, hostnames []HostName) error {
+	return client.SetHostnamesCtx(context.Background(), clusterid, hostnames)
+}
+
+func (client *ApiClient) SetHostnamesCtx(ctx context.Context, clusterid string, hostnames []HostName) error {
 	var hnl HostNameList
 	hnl.HostNames = hostnames
 
@@ -74,69 +75,194 @@ func (client *ApiClient) SetHostnames(clusterid string, hostnames []HostName) er
 		return err
 	}
 
-	req, err := client.NewRequest(
+	return client.doRequest(
+		ctx,
 		"PATCH",
 		fmt.Sprintf("%s/clusters/%s", client.ApiUrl, clusterid),
-		bytes.NewReader(hnljson),
+		hnljson, http.StatusCreated,
+		"set hostnames",
+		nil,
+	)
+}
+
+func (client *ApiClient) GetHost(clusterid, hostid string) (*Host, error) {
+	return client.GetHostCtx(context.Background(), clusterid, hostid)
+}
+
+func (client *ApiClient) GetHostCtx(ctx context.Context, clusterid, hostid string) (*Host, error) {
+	var host Host
+
+	err := client.doRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/clusters/%s/hosts/%s", client.ApiUrl, clusterid, hostid),
+		nil, http.StatusOK,
+		fmt.Sprintf("get host %s", hostid),
+		&host,
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return err
+
+	return &host, nil
+}
+
+var supportedHostRoles = []string{
+	"master",
+	"worker",
+	"auto-assign",
+}
+
+func ValidateHostRole(role string) bool {
+	return ValInList(role, supportedHostRoles)
+}
+
+// RequiredMasters returns the number of control plane hosts the
+// cluster needs: one for single node clusters, three otherwise.
+func (cluster *Cluster) RequiredMasters() int {
+	if cluster.HighAvailabilityMode == "None" {
+		return 1
 	}
 
-	// XXX: We should factor response error handling into a common
-	// function.
-	if resp.StatusCode != 201 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
+	return 3
+}
+
+// ValidateHostRoles checks that the cluster would still be able to
+// get the right number of control plane hosts after assigning the
+// given roles. Hosts with the auto-assign role may become masters,
+// so they count towards the minimum but not the maximum.
+func (cluster *Cluster) ValidateHostRoles(hostRoles []HostRole) error {
+	roles := cluster.hostRoles()
+
+	_, before := countMasters(roles)
+
+	for _, hostRole := range hostRoles {
+		if _, ok := roles[hostRole.ID]; !ok {
+			return fmt.Errorf("host %s is not part of cluster %s", hostRole.ID, cluster.Name)
+		}
+		if !ValidateHostRole(hostRole.Role) {
+			return fmt.Errorf("invalid role %q for host %s: must be one of %v",
+				hostRole.Role, hostRole.ID, supportedHostRoles)
 		}
-		return fmt.Errorf(
-			"failed to set hostname: %s [%d]: %s",
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
+		roles[hostRole.ID] = hostRole.Role
+	}
+
+	masters, candidates := countMasters(roles)
+	required := cluster.RequiredMasters()
+
+	if masters > required {
+		return fmt.Errorf("cluster %s needs exactly %d master(s), but %d hosts would be masters",
+			cluster.Name, required, masters)
+	}
+
+	// Clusters that are still waiting for hosts to register may not
+	// have enough candidates yet; only complain if these roles make
+	// things worse.
+	if candidates < required && candidates < before {
+		return fmt.Errorf("cluster %s needs exactly %d master(s), but only %d host(s) could be masters",
+			cluster.Name, required, candidates)
 	}
 
 	return nil
 }
 
-func (client *ApiClient) GetHost(clusterid, hostid string) (*Host, error) {
-	var host Host
+// NormalizeHostRole returns the role that a host was given, as one of
+// the roles accepted by ValidateHostRole. The api reports the
+// master that bootstraps the installation as "bootstrap", and a host
+// that has never been given a role may have an empty role.
+func NormalizeHostRole(role string) string {
+	switch role {
+	case "bootstrap":
+		return "master"
+	case "":
+		return "auto-assign"
+	default:
+		return role
+	}
+}
 
-	req, err := client.NewRequest(
-		"GET",
-		fmt.Sprintf("%s/clusters/%s/hosts/%s", client.ApiUrl, clusterid, hostid),
-		nil,
-	)
-	if err != nil {
-		return nil, err
+// Return a map of host ids to roles.
+func (cluster *Cluster) hostRoles() map[string]string {
+	roles := make(map[string]string)
+
+	for _, host := range cluster.Hosts {
+		roles[host.ID] = host.Role
 	}
-	resp, err := client.client.Do(req)
-	if err != nil {
-		return nil, err
+
+	return roles
+}
+
+// CountMasters returns the number of hosts in the cluster that are
+// masters, and the number that are or could become masters.
+func (cluster *Cluster) CountMasters() (int, int) {
+	return countMasters(cluster.hostRoles())
+}
+
+// Count the hosts that are masters, and the hosts that are or could
+// become masters, given a map of host ids to roles.
+func countMasters(roles map[string]string) (int, int) {
+	masters, candidates := 0, 0
+
+	for _, role := range roles {
+		switch NormalizeHostRole(role) {
+		case "master":
+			masters++
+			candidates++
+		case "auto-assign":
+			candidates++
+		}
 	}
-	if resp.StatusCode != 200 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			body = []byte("unknown error")
+
+	return masters, candidates
+}
+
+// FindHost looks for a host in the cluster by id or requested
+// hostname.
+func (cluster *Cluster) FindHost(ref string) (*Host, error) {
+	for i := range cluster.Hosts {
+		host := &cluster.Hosts[i]
+		if host.ID == ref || host.RequestedHostname == ref {
+			return host, nil
 		}
-		return nil, fmt.Errorf(
-			"failed to get host %s: %s [%d]: %s",
-			hostid,
-			http.StatusText(resp.StatusCode), resp.StatusCode, body,
-		)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	return nil, fmt.Errorf("no host matching %s", ref)
+}
+
+// FindDisk looks for a disk in the inventory by name (e.g. "sda"),
+// path, /dev/disk/by-id or by-path link, serial number, WWN or id.
+func (inventory *HostInventory) FindDisk(ref string) (*Disks, error) {
+	var found []Disks
+
+	for _, disk := range inventory.Disks {
+		for _, value := range []string{
+			disk.ID, disk.Name, disk.Path, disk.ByID, disk.ByPath, disk.Serial, disk.Wwn,
+		} {
+			if value != "" && value == ref {
+				found = append(found, disk)
+				break
+			}
+		}
 	}
-	if err := json.Unmarshal(body, &host); err != nil {
-		return nil, err
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no disk matching %s", ref)
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, fmt.Errorf("more than one disk matches %s", ref)
 	}
+}
 
-	return &host, nil
+// HasMacAddress returns true if any interface in the inventory has the
+// given MAC address.
+func (inventory *HostInventory) HasMacAddress(mac string) bool {
+	for _, iface := range inventory.Interfaces {
+		if strings.EqualFold(iface.MacAddress, mac) {
+			return true
+		}
+	}
+
+	return false
 }
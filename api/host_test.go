@@ -0,0 +1,30 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCountMasters(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		roles      []string
+		masters    int
+		candidates int
+	}{
+		{"no hosts", nil, 0, 0},
+		{"unassigned", []string{"", "auto-assign", "worker"}, 0, 2},
+		{"installing", []string{"bootstrap", "master", "master", "worker"}, 3, 3},
+	} {
+		var cluster Cluster
+		for i, role := range tc.roles {
+			cluster.Hosts = append(cluster.Hosts, Host{ID: fmt.Sprintf("h%d", i), Role: role})
+		}
+
+		masters, candidates := cluster.CountMasters()
+		if masters != tc.masters || candidates != tc.candidates {
+			t.Errorf("%s: got %d masters and %d candidates, want %d and %d",
+				tc.name, masters, candidates, tc.masters, tc.candidates)
+		}
+	}
+}
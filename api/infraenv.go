@@ -0,0 +1,308 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// V2Url returns the base url for the v2 assisted installer api, which
+// is where infra-envs live. We derive it from ApiUrl by replacing the
+// trailing version component.
+func (client *ApiClient) V2Url() string {
+	base := strings.TrimSuffix(client.ApiUrl, "/")
+	return fmt.Sprintf("%s/v2", strings.TrimSuffix(base, "/v1"))
+}
+
+func (client *ApiClient) ListInfraEnvs(clusterid string) (InfraEnvList, error) {
+	return client.ListInfraEnvsCtx(context.Background(), clusterid)
+}
+
+// List available infra-envs. If clusterid is not empty, only list
+// infra-envs associated with that cluster.
+func (client *ApiClient) ListInfraEnvsCtx(ctx context.Context, clusterid string) (InfraEnvList, error) {
+	var infraEnvs InfraEnvList
+
+	endpoint := fmt.Sprintf("%s/infra-envs", client.V2Url())
+	if clusterid != "" {
+		endpoint = fmt.Sprintf("%s?cluster_id=%s", endpoint, url.QueryEscape(clusterid))
+	}
+
+	err := client.doRequest(
+		ctx,
+		"GET",
+		endpoint,
+		nil, http.StatusOK,
+		"list infra-envs",
+		&infraEnvs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return infraEnvs, nil
+}
+
+func (client *ApiClient) FindInfraEnv(infraenvid string) (*InfraEnv, error) {
+	return client.FindInfraEnvCtx(context.Background(), infraenvid)
+}
+
+// Find an infra-env by id or name, in the same way as FindCluster.
+func (client *ApiClient) FindInfraEnvCtx(ctx context.Context, infraenvid string) (*InfraEnv, error) {
+	if isID(infraenvid) {
+		infraEnv, err := client.GetInfraEnvCtx(ctx, infraenvid)
+		if err == nil {
+			return infraEnv, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	infraEnvs, err := client.ListInfraEnvsCtx(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, infraEnv := range infraEnvs {
+		if infraEnv.Name == infraenvid {
+			return client.GetInfraEnvCtx(ctx, infraEnv.ID)
+		}
+	}
+
+	return nil, fmt.Errorf("no infra-env matching %s", infraenvid)
+}
+
+func (client *ApiClient) GetInfraEnv(infraenvid string) (*InfraEnv, error) {
+	return client.GetInfraEnvCtx(context.Background(), infraenvid)
+}
+
+func (client *ApiClient) GetInfraEnvCtx(ctx context.Context, infraenvid string) (*InfraEnv, error) {
+	var infraEnv InfraEnv
+
+	err := client.doRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/infra-envs/%s", client.V2Url(), infraenvid),
+		nil, http.StatusOK,
+		fmt.Sprintf("get infra-env %s", infraenvid),
+		&infraEnv,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &infraEnv, nil
+}
+
+func (client *ApiClient) CreateInfraEnv(params *InfraEnvCreateParams) (*InfraEnv, error) {
+	return client.CreateInfraEnvCtx(context.Background(), params)
+}
+
+func (client *ApiClient) CreateInfraEnvCtx(ctx context.Context, params *InfraEnvCreateParams) (*InfraEnv, error) {
+	paramsJson, err := params.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var infraEnv InfraEnv
+	err = client.doRequest(
+		ctx,
+		"POST",
+		fmt.Sprintf("%s/infra-envs", client.V2Url()),
+		paramsJson, http.StatusCreated,
+		fmt.Sprintf("create infra-env %s", params.Name),
+		&infraEnv,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &infraEnv, nil
+}
+
+func (client *ApiClient) UpdateInfraEnv(infraenvid string, params *InfraEnvUpdateParams) (*InfraEnv, error) {
+	return client.UpdateInfraEnvCtx(context.Background(), infraenvid, params)
+}
+
+func (client *ApiClient) UpdateInfraEnvCtx(
+	ctx context.Context, infraenvid string, params *InfraEnvUpdateParams) (*InfraEnv, error) {
+	paramsJson, err := params.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var infraEnv InfraEnv
+	err = client.doRequest(
+		ctx,
+		"PATCH",
+		fmt.Sprintf("%s/infra-envs/%s", client.V2Url(), infraenvid),
+		paramsJson, http.StatusCreated,
+		fmt.Sprintf("update infra-env %s", infraenvid),
+		&infraEnv,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &infraEnv, nil
+}
+
+func (client *ApiClient) DeleteInfraEnv(infraenvid string) error {
+	return client.DeleteInfraEnvCtx(context.Background(), infraenvid)
+}
+
+func (client *ApiClient) DeleteInfraEnvCtx(ctx context.Context, infraenvid string) error {
+	return client.doRequest(
+		ctx,
+		"DELETE",
+		fmt.Sprintf("%s/infra-envs/%s", client.V2Url(), infraenvid),
+		nil, http.StatusNoContent,
+		fmt.Sprintf("delete infra-env %s", infraenvid),
+		nil,
+	)
+}
+
+func (client *ApiClient) GetInfraEnvImageUrl(infraenvid string) (*PresignedUrl, error) {
+	return client.GetInfraEnvImageUrlCtx(context.Background(), infraenvid)
+}
+
+// Get a (new) presigned download url for the infra-env's discovery
+// image.
+func (client *ApiClient) GetInfraEnvImageUrlCtx(ctx context.Context, infraenvid string) (*PresignedUrl, error) {
+	var imageUrl PresignedUrl
+
+	err := client.doRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/infra-envs/%s/downloads/image-url", client.V2Url(), infraenvid),
+		nil, http.StatusOK,
+		fmt.Sprintf("get image url for infra-env %s", infraenvid),
+		&imageUrl,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &imageUrl, nil
+}
+
+func (client *ApiClient) ListInfraEnvHosts(infraenvid string) ([]Host, error) {
+	return client.ListInfraEnvHostsCtx(context.Background(), infraenvid)
+}
+
+func (client *ApiClient) ListInfraEnvHostsCtx(ctx context.Context, infraenvid string) ([]Host, error) {
+	var hosts []Host
+
+	err := client.doRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/infra-envs/%s/hosts", client.V2Url(), infraenvid),
+		nil, http.StatusOK,
+		fmt.Sprintf("list hosts in infra-env %s", infraenvid),
+		&hosts,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
+
+func (client *ApiClient) GetInfraEnvHost(infraenvid, hostid string) (*Host, error) {
+	return client.GetInfraEnvHostCtx(context.Background(), infraenvid, hostid)
+}
+
+func (client *ApiClient) GetInfraEnvHostCtx(ctx context.Context, infraenvid, hostid string) (*Host, error) {
+	var host Host
+
+	err := client.doRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/infra-envs/%s/hosts/%s", client.V2Url(), infraenvid, hostid),
+		nil, http.StatusOK,
+		fmt.Sprintf("get host %s", hostid),
+		&host,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+func (client *ApiClient) FindInfraEnvHost(infraenvid, hostid string) (*Host, error) {
+	return client.FindInfraEnvHostCtx(context.Background(), infraenvid, hostid)
+}
+
+// Find a host in an infra-env by id or requested hostname.
+func (client *ApiClient) FindInfraEnvHostCtx(ctx context.Context, infraenvid, hostid string) (*Host, error) {
+	if isID(infraenvid) && isID(hostid) {
+		host, err := client.GetInfraEnvHostCtx(ctx, infraenvid, hostid)
+		if err == nil {
+			return host, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	hosts, err := client.ListInfraEnvHostsCtx(ctx, infraenvid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, host := range hosts {
+		if host.RequestedHostname == hostid {
+			return &host, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no host matching %s", hostid)
+}
+
+func (client *ApiClient) DeleteInfraEnvHost(infraenvid, hostid string) error {
+	return client.DeleteInfraEnvHostCtx(context.Background(), infraenvid, hostid)
+}
+
+func (client *ApiClient) DeleteInfraEnvHostCtx(ctx context.Context, infraenvid, hostid string) error {
+	return client.doRequest(
+		ctx,
+		"DELETE",
+		fmt.Sprintf("%s/infra-envs/%s/hosts/%s", client.V2Url(), infraenvid, hostid),
+		nil, http.StatusNoContent,
+		fmt.Sprintf("delete host %s", hostid),
+		nil,
+	)
+}
+
+func (infraEnv *InfraEnv) ToJSON() ([]byte, error) {
+	infraEnvJson, err := json.Marshal(infraEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	return infraEnvJson, nil
+}
+
+func (params *InfraEnvCreateParams) ToJSON() ([]byte, error) {
+	paramsJson, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return paramsJson, nil
+}
+
+func (params *InfraEnvUpdateParams) ToJSON() ([]byte, error) {
+	paramsJson, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return paramsJson, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+var supportedLogsTypes = []string{
+	"all",
+	"controller",
+	"host",
+}
+
+func ValidateLogsType(logsType string) bool {
+	return ValInList(logsType, supportedLogsTypes)
+}
+
+func (client *ApiClient) DownloadLogs(clusterid, logsType, hostid string, w io.Writer) error {
+	return client.DownloadLogsCtx(context.Background(), clusterid, logsType, hostid, w)
+}
+
+// DownloadLogsCtx writes a tarball of cluster logs to w. logsType is
+// one of "all", "controller" or "host"; host logs require a hostid.
+func (client *ApiClient) DownloadLogsCtx(ctx context.Context, clusterid, logsType, hostid string, w io.Writer) error {
+	query := url.Values{}
+	query.Set("logs_type", logsType)
+	if hostid != "" {
+		query.Set("host_id", hostid)
+	}
+
+	operation := fmt.Sprintf("download %s logs for cluster %s", logsType, clusterid)
+	if hostid != "" {
+		operation = fmt.Sprintf("download logs for host %s", hostid)
+	}
+
+	// Log tarballs can be large, so the request timeout only applies
+	// to waiting for the server to start sending them.
+	return client.doRequest(
+		withDownload(ctx),
+		"GET",
+		fmt.Sprintf("%s/clusters/%s/logs?%s", client.ApiUrl, clusterid, query.Encode()),
+		nil, http.StatusOK,
+		operation,
+		w,
+	)
+}
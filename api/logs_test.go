@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDownloadLogsTimeout(t *testing.T) {
+	timeout := 100 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("logs_type") == "controller" {
+			// Don't respond until after the timeout.
+			time.Sleep(3 * timeout)
+		}
+
+		// Send the body slowly, so that reading all of it takes
+		// longer than the timeout.
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("logs"))
+			w.(http.Flusher).Flush()
+			time.Sleep(timeout)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewApiClientWithAuth(server.URL, AuthConfig{Mode: AuthModeNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetTimeout(timeout)
+
+	var out bytes.Buffer
+	if err := client.DownloadLogs("c1", "all", "", &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "logslogslogs" {
+		t.Errorf("got %q, want %q", out.String(), "logslogslogs")
+	}
+
+	// Downloads share one client, rather than building a new one
+	// (and a new connection pool) each time.
+	ctx := withDownload(context.Background())
+	if download := client.httpClient(ctx); download == client.client || download != client.httpClient(ctx) {
+		t.Errorf("downloads don't share a client")
+	}
+
+	client.Retry.MaxAttempts = 1
+	if err := client.DownloadLogs("c1", "controller", "", &out); err == nil {
+		t.Errorf("expected a timeout waiting for the response")
+	}
+}
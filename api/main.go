@@ -1,12 +1,17 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -15,50 +20,267 @@ type (
 	ApiClient struct {
 		AccessToken string
 		ApiUrl      string
+		Retry       RetryPolicy
 		client      *http.Client
+
+		// The client used for downloads, which is built from client
+		// whenever its timeout or transport change.
+		downloadClient *http.Client
+
+		auth           AuthConfig
+		tokenExpiresAt time.Time
+		tokenLock      sync.Mutex
 	}
 )
 
 var ssourl = "https://sso.redhat.com/auth/realms/redhat-external/protocol/openid-connect/token"
 
+// We refresh the access token this long before it actually expires so
+// that a request sent just before the deadline doesn't get rejected.
+var tokenExpiryMargin = 60 * time.Second
+
+// NewApiClient returns a client that authenticates using Red Hat SSO,
+// or nil if we are unable to acquire an access token.
 func NewApiClient(offlinetoken string, apiurl string) *ApiClient {
+	client, err := NewApiClientWithAuth(apiurl, AuthConfig{
+		Mode:         AuthModeRHSSO,
+		OfflineToken: offlinetoken,
+	})
+	if err != nil {
+		return nil
+	}
+
+	if err := client.GetAccessToken(offlinetoken); err != nil {
+		return nil
+	}
+
+	return client
+}
+
+// NewApiClientWithAuth returns a client that authenticates as
+// described by auth. If the auth mode uses an offline token, the
+// first access token is acquired when the client makes its first
+// request.
+func NewApiClientWithAuth(apiurl string, auth AuthConfig) (*ApiClient, error) {
+	if err := auth.validate(); err != nil {
+		return nil, err
+	}
+
 	client := ApiClient{
 		ApiUrl: apiurl,
+		Retry:  DefaultRetryPolicy,
 		client: &http.Client{},
+		auth:   auth,
 	}
 
-	if err := client.GetAccessToken(offlinetoken); err != nil {
-		return nil
+	if auth.Mode == AuthModeStaticBearer {
+		client.AccessToken = auth.Token
+	}
+
+	return &client, nil
+}
+
+// AuthMode returns the way the client authenticates to the api.
+func (client *ApiClient) AuthMode() AuthMode {
+	return client.auth.Mode
+}
+
+// SetTimeout sets the time limit for requests made by this client. A
+// timeout of zero means no timeout. For downloads the limit applies
+// only to waiting for the response headers, not to reading the body.
+func (client *ApiClient) SetTimeout(timeout time.Duration) {
+	client.client.Timeout = timeout
+	client.updateDownloadClient()
+}
+
+// SetCABundle adds the PEM-encoded certificates in bundle to the set
+// of certificate authorities trusted by this client.
+func (client *ApiClient) SetCABundle(bundle []byte) error {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Debugf("unable to load system certificates: %v", err)
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(bundle) {
+		return fmt.Errorf("no certificates found in ca bundle")
 	}
 
-	return &client
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	client.client.Transport = transport
+	client.updateDownloadClient()
+
+	return nil
 }
 
 func (client *ApiClient) NewRequest(method, url string, body io.Reader) (*http.Request, error) {
+	return client.NewRequestCtx(context.Background(), method, url, body)
+}
+
+func (client *ApiClient) NewRequestCtx(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
 	log.Debugf("creating %s request for %s", method, url)
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", client.AccessToken))
 	req.Header.Add("Content-type", "application/json")
 
 	return req, nil
 }
 
+// Do sends a request to the API. The access token is refreshed before
+// sending the request if it has expired (or is about to), and if the
+// server rejects the token anyway we refresh it and retry the request
+// once.
+func (client *ApiClient) Do(req *http.Request) (*http.Response, error) {
+	token, err := client.validAccessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	resp, err := client.httpClient(req.Context()).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized || !client.auth.refreshable() {
+		return resp, nil
+	}
+
+	log.Debugf("request for %s was unauthorized; refreshing access token", req.URL)
+	resp.Body.Close()
+
+	if err := client.RefreshAccessTokenCtx(req.Context()); err != nil {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	token, err = client.validAccessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	retry.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	return client.httpClient(req.Context()).Do(retry)
+}
+
+type downloadKey struct{}
+
+// Mark requests made with the returned context as downloads, whose
+// response bodies may take much longer than the request timeout to
+// read.
+func withDownload(ctx context.Context) context.Context {
+	return context.WithValue(ctx, downloadKey{}, true)
+}
+
+// Return the http client to use for a request. Downloads get a client
+// whose timeout covers only waiting for the response headers.
+func (client *ApiClient) httpClient(ctx context.Context) *http.Client {
+	if ctx.Value(downloadKey{}) == nil || client.downloadClient == nil {
+		return client.client
+	}
+
+	return client.downloadClient
+}
+
+// Build the client used for downloads. Its transport is a copy of the
+// one used for other requests, so that connections are shared between
+// downloads but not with requests that have an overall timeout.
+func (client *ApiClient) updateDownloadClient() {
+	if client.client.Timeout == 0 {
+		client.downloadClient = nil
+		return
+	}
+
+	transport, ok := client.client.Transport.(*http.Transport)
+	if !ok || transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+
+	transport = transport.Clone()
+	transport.ResponseHeaderTimeout = client.client.Timeout
+
+	client.downloadClient = &http.Client{Transport: transport}
+}
+
+// Return the current access token, first refreshing it if it has
+// expired.
+func (client *ApiClient) validAccessToken(ctx context.Context) (string, error) {
+	client.tokenLock.Lock()
+	token := client.AccessToken
+	expired := client.auth.refreshable() && (token == "" ||
+		!client.tokenExpiresAt.IsZero() &&
+			time.Now().Add(tokenExpiryMargin).After(client.tokenExpiresAt))
+	client.tokenLock.Unlock()
+
+	if expired {
+		log.Debugf("access token missing or expired; refreshing")
+		if err := client.RefreshAccessTokenCtx(ctx); err != nil {
+			return "", err
+		}
+
+		client.tokenLock.Lock()
+		token = client.AccessToken
+		client.tokenLock.Unlock()
+	}
+
+	return token, nil
+}
+
+// RefreshAccessToken acquires a new access token using the offline
+// token from the most recent call to GetAccessToken.
+func (client *ApiClient) RefreshAccessToken() error {
+	return client.RefreshAccessTokenCtx(context.Background())
+}
+
+func (client *ApiClient) RefreshAccessTokenCtx(ctx context.Context) error {
+	return client.GetAccessTokenCtx(ctx, client.auth.OfflineToken)
+}
+
 func (client *ApiClient) GetAccessToken(offlinetoken string) error {
+	return client.GetAccessTokenCtx(context.Background(), offlinetoken)
+}
+
+func (client *ApiClient) GetAccessTokenCtx(ctx context.Context, offlinetoken string) error {
 	var response TokenResponse
 
+	client.tokenLock.Lock()
+	defer client.tokenLock.Unlock()
+
+	if !client.auth.refreshable() {
+		return fmt.Errorf("auth mode %s does not use access tokens", client.auth.Mode)
+	}
+
 	params := url.Values{}
-	params.Add("client_id", "cloud-services")
+	params.Add("client_id", client.auth.ClientID)
 	params.Add("grant_type", "refresh_token")
 	params.Add("refresh_token", strings.TrimSuffix(string(offlinetoken), "\n"))
 
-	log.Debugf("asking %s for access token", ssourl)
-	resp, err := client.client.PostForm(ssourl, params)
+	log.Debugf("asking %s for access token", client.auth.TokenUrl)
+	req, err := http.NewRequestWithContext(
+		ctx, "POST", client.auth.TokenUrl, strings.NewReader(params.Encode()))
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to acquire token: %w", err)
+	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode != 200 {
 		return fmt.Errorf(
 			"failed to acquire token: %s",
@@ -66,7 +288,6 @@ func (client *ApiClient) GetAccessToken(offlinetoken string) error {
 		)
 	}
 
-	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return err
@@ -77,6 +298,12 @@ func (client *ApiClient) GetAccessToken(offlinetoken string) error {
 	}
 
 	client.AccessToken = response.AccessToken
+	client.auth.OfflineToken = offlinetoken
+	client.tokenExpiresAt = time.Time{}
+	if response.ExpiresIn > 0 {
+		client.tokenExpiresAt = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+		log.Debugf("access token expires at %s", client.tokenExpiresAt)
+	}
 
 	return nil
 }
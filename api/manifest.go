@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+func manifestQuery(folder, filename string) string {
+	params := url.Values{}
+	params.Add("folder", folder)
+	params.Add("file_name", filename)
+
+	return params.Encode()
+}
+
+func (client *ApiClient) ListManifests(clusterid string) ([]Manifest, error) {
+	return client.ListManifestsCtx(context.Background(), clusterid)
+}
+
+func (client *ApiClient) ListManifestsCtx(ctx context.Context, clusterid string) ([]Manifest, error) {
+	var manifests []Manifest
+
+	err := client.doRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/clusters/%s/manifests", client.ApiUrl, clusterid),
+		nil, http.StatusOK,
+		fmt.Sprintf("list manifests for cluster %s", clusterid),
+		&manifests,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifests, nil
+}
+
+func (client *ApiClient) GetManifest(clusterid, folder, filename string) ([]byte, error) {
+	return client.GetManifestCtx(context.Background(), clusterid, folder, filename)
+}
+
+func (client *ApiClient) GetManifestCtx(ctx context.Context, clusterid, folder, filename string) ([]byte, error) {
+	var content []byte
+
+	err := client.doRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/clusters/%s/manifests/files?%s",
+			client.ApiUrl, clusterid, manifestQuery(folder, filename)),
+		nil, http.StatusOK,
+		fmt.Sprintf("fetch manifest %s/%s", folder, filename),
+		&content,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+func (client *ApiClient) CreateManifest(clusterid, folder, filename string, content []byte) error {
+	return client.CreateManifestCtx(context.Background(), clusterid, folder, filename, content)
+}
+
+func (client *ApiClient) CreateManifestCtx(
+	ctx context.Context, clusterid, folder, filename string, content []byte) error {
+	params := CreateManifestParams{
+		FileName: filename,
+		Folder:   folder,
+		Content:  base64.StdEncoding.EncodeToString(content),
+	}
+
+	paramsJson, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return client.doRequest(
+		ctx,
+		"POST",
+		fmt.Sprintf("%s/clusters/%s/manifests", client.ApiUrl, clusterid),
+		paramsJson, http.StatusCreated,
+		fmt.Sprintf("create manifest %s/%s", folder, filename),
+		nil,
+	)
+}
+
+func (client *ApiClient) DeleteManifest(clusterid, folder, filename string) error {
+	return client.DeleteManifestCtx(context.Background(), clusterid, folder, filename)
+}
+
+func (client *ApiClient) DeleteManifestCtx(ctx context.Context, clusterid, folder, filename string) error {
+	return client.doRequest(
+		ctx,
+		"DELETE",
+		fmt.Sprintf("%s/clusters/%s/manifests?%s",
+			client.ApiUrl, clusterid, manifestQuery(folder, filename)),
+		nil, http.StatusNoContent,
+		fmt.Sprintf("delete manifest %s/%s", folder, filename),
+		nil,
+	)
+}
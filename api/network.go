@@ -0,0 +1,112 @@
+package api
+
+import (
+	"fmt"
+	"net"
+)
+
+// ClusterNetworkCidrs returns the cluster (pod) networks of the
+// cluster. Older versions of the api only provide the single
+// cluster_network_cidr field.
+func (cluster *Cluster) ClusterNetworkCidrs() []string {
+	cidrs := clusterNetworkCidrs(cluster.ClusterNetworks)
+	if len(cidrs) == 0 && cluster.ClusterNetworkCidr != "" {
+		cidrs = append(cidrs, cluster.ClusterNetworkCidr)
+	}
+
+	return cidrs
+}
+
+// ServiceNetworkCidrs returns the service networks of the cluster.
+func (cluster *Cluster) ServiceNetworkCidrs() []string {
+	cidrs := serviceNetworkCidrs(cluster.ServiceNetworks)
+	if len(cidrs) == 0 && cluster.ServiceNetworkCidr != "" {
+		cidrs = append(cidrs, cluster.ServiceNetworkCidr)
+	}
+
+	return cidrs
+}
+
+// MachineNetworkCidrs returns the machine networks of the cluster.
+func (cluster *Cluster) MachineNetworkCidrs() []string {
+	cidrs := machineNetworkCidrs(cluster.MachineNetworks)
+	if len(cidrs) == 0 && cluster.MachineNetworkCidr != "" {
+		cidrs = append(cidrs, cluster.MachineNetworkCidr)
+	}
+
+	return cidrs
+}
+
+func clusterNetworkCidrs(networks []ClusterNetwork) []string {
+	var cidrs []string
+
+	for _, network := range networks {
+		cidrs = append(cidrs, network.Cidr)
+	}
+
+	return cidrs
+}
+
+func serviceNetworkCidrs(networks []ServiceNetwork) []string {
+	var cidrs []string
+
+	for _, network := range networks {
+		cidrs = append(cidrs, network.Cidr)
+	}
+
+	return cidrs
+}
+
+func machineNetworkCidrs(networks []MachineNetwork) []string {
+	var cidrs []string
+
+	for _, network := range networks {
+		cidrs = append(cidrs, network.Cidr)
+	}
+
+	return cidrs
+}
+
+// ServiceNetworksFromCidrs converts a list of CIDRs to the form used
+// by the service_networks field.
+func ServiceNetworksFromCidrs(cidrs []string) []ServiceNetwork {
+	var networks []ServiceNetwork
+
+	for _, cidr := range cidrs {
+		networks = append(networks, ServiceNetwork{Cidr: cidr})
+	}
+
+	return networks
+}
+
+// MachineNetworksFromCidrs converts a list of CIDRs to the form used
+// by the machine_networks field.
+func MachineNetworksFromCidrs(cidrs []string) []MachineNetwork {
+	var networks []MachineNetwork
+
+	for _, cidr := range cidrs {
+		networks = append(networks, MachineNetwork{Cidr: cidr})
+	}
+
+	return networks
+}
+
+func isIPv4(ip net.IP) bool {
+	return ip.To4() != nil
+}
+
+// Check that a list of networks is either a single network or a
+// dual-stack pair (IPv4 first, then IPv6).
+func validateNetworkFamilies(name string, networks []*net.IPNet) error {
+	switch len(networks) {
+	case 0, 1:
+		return nil
+	case 2:
+		if !isIPv4(networks[0].IP) || isIPv4(networks[1].IP) {
+			return fmt.Errorf("dual-stack %ss must be one IPv4 network followed by one IPv6 network", name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("too many %ss: expected at most one IPv4 and one IPv6 network", name)
+	}
+}
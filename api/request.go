@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Send a request to the API and check that the response has the
+// expected status code. On success the caller is responsible for
+// closing the response body; on failure the body has already been
+// consumed and is returned as part of an *APIError.
+//
+// Idempotent requests that fail with a transient error are retried
+// according to the client's RetryPolicy.
+//
+// The operation argument describes the request for use in error
+// messages (e.g. "get cluster 1234").
+func (client *ApiClient) send(ctx context.Context, method, url string, body []byte, expect int, operation string) (*http.Response, error) {
+	var resp *http.Response
+
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+
+		req, err := client.NewRequestCtx(ctx, method, url, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = client.Do(req)
+
+		if !isIdempotent(method) || attempt >= client.Retry.MaxAttempts {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if !isTransientError(err) {
+				return nil, err
+			}
+			delay = client.Retry.Backoff(attempt)
+			log.Infof("%s: %v (attempt %d of %d); retrying in %s",
+				operation, err, attempt, client.Retry.MaxAttempts, delay)
+		case isTransientStatus(resp.StatusCode):
+			delay = client.Retry.Backoff(attempt)
+			if after, ok := retryAfter(resp); ok {
+				delay = client.Retry.limit(after)
+			}
+			resp.Body.Close()
+			log.Infof("%s: %s (attempt %d of %d); retrying in %s",
+				operation, resp.Status, attempt, client.Retry.MaxAttempts, delay)
+		default:
+			return client.checkResponse(resp, expect, operation)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	return client.checkResponse(resp, expect, operation)
+}
+
+// Return an *APIError if the response status is not the expected
+// one.
+func (client *ApiClient) checkResponse(resp *http.Response, expect int, operation string) (*http.Response, error) {
+	if resp.StatusCode != expect {
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		if err != nil {
+			content = []byte("unknown error")
+		}
+		return nil, newAPIError(operation, resp.StatusCode, content)
+	}
+
+	return resp, nil
+}
+
+// Send a request to the API (see send) and decode the response into
+// result. If result is a *[]byte it receives the raw response body,
+// and if it is an io.Writer the body is copied to it; if it is nil the
+// response body is discarded.
+func (client *ApiClient) doRequest(ctx context.Context,
+	method, url string, body []byte, expect int, operation string, result interface{}) error {
+	resp, err := client.send(ctx, method, url, body, expect, operation)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch result := result.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*result, err = io.ReadAll(resp.Body)
+		return err
+	case io.Writer:
+		_, err = io.Copy(result, resp.Body)
+		return err
+	default:
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+}
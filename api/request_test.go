@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     10 * time.Millisecond,
+}
+
+// Start a server that answers requests with the given status codes in
+// turn (and 200 once it runs out), and return a client for it and a
+// pointer to the number of requests it has seen.
+func newRetryTestServer(t *testing.T, header http.Header, statuses ...int) (*ApiClient, *int) {
+	count := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		if count <= len(statuses) {
+			w.WriteHeader(statuses[count-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewApiClientWithAuth(server.URL, AuthConfig{Mode: AuthModeNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Retry = testRetryPolicy
+
+	return client, &count
+}
+
+func TestRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		method   string
+		statuses []int
+		ok       bool
+		requests int
+	}{
+		{"success", "GET", nil, true, 1},
+		{"503 then success", "GET", []int{503}, true, 2},
+		{"429 twice then success", "GET", []int{429, 429}, true, 3},
+		{"gives up", "GET", []int{503, 502, 504, 503}, false, 3},
+		{"not transient", "GET", []int{500}, false, 1},
+		{"post is not retried", "POST", []int{503}, false, 1},
+	} {
+		client, count := newRetryTestServer(t, nil, tc.statuses...)
+
+		err := client.doRequest(context.Background(), tc.method, client.ApiUrl+"/clusters",
+			nil, http.StatusOK, "test", nil)
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if *count != tc.requests {
+			t.Errorf("%s: got %d requests, want %d", tc.name, *count, tc.requests)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"3600"}}
+	client, count := newRetryTestServer(t, header, 429, 503)
+
+	start := time.Now()
+	err := client.doRequest(context.Background(), "GET", client.ApiUrl+"/clusters",
+		nil, http.StatusOK, "test", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *count != 3 {
+		t.Errorf("got %d requests, want 3", *count)
+	}
+
+	// The hour asked for by the server is limited to MaxBackoff.
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retries took %s", elapsed)
+	}
+}
+
+func TestRetryAfterLimit(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+	}{
+		{"0", 0},
+		{"1", testRetryPolicy.MaxBackoff},
+		{"3600", testRetryPolicy.MaxBackoff},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	} {
+		resp := http.Response{Header: http.Header{"Retry-After": []string{tc.value}}}
+
+		after, ok := retryAfter(&resp)
+		if !ok {
+			t.Errorf("%s: not parsed", tc.value)
+			continue
+		}
+
+		if got := testRetryPolicy.limit(after); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestRefreshOnUnauthorized(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+
+		// The token the api accepts.
+		valid string
+
+		ok            bool
+		tokenRequests int
+		apiRequests   int
+	}{
+		{"refreshed token accepted", "token-2", true, 2, 2},
+		{"refreshed token rejected", "token-9", false, 2, 2},
+	} {
+		tokenRequests, apiRequests := 0, 0
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+			tokenRequests++
+			json.NewEncoder(w).Encode(TokenResponse{
+				AccessToken: "token-" + string(rune('0'+tokenRequests)),
+				ExpiresIn:   300,
+			})
+		})
+		mux.HandleFunc("/clusters", func(w http.ResponseWriter, r *http.Request) {
+			apiRequests++
+			if r.Header.Get("Authorization") != "Bearer "+tc.valid {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("[]"))
+		})
+
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		client, err := NewApiClientWithAuth(server.URL, AuthConfig{
+			Mode:         AuthModeCustomOIDC,
+			OfflineToken: "offline",
+			TokenUrl:     server.URL + "/token",
+			ClientID:     "test",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.Retry = testRetryPolicy
+
+		_, err = client.ListClusters()
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if !tc.ok && !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("%s: got error %v, want %v", tc.name, err, ErrUnauthorized)
+		}
+
+		if tokenRequests != tc.tokenRequests {
+			t.Errorf("%s: got %d token requests, want %d", tc.name, tokenRequests, tc.tokenRequests)
+		}
+		if apiRequests != tc.apiRequests {
+			t.Errorf("%s: got %d api requests, want %d", tc.name, apiRequests, tc.apiRequests)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type (
+	// RetryPolicy controls how idempotent requests are retried when
+	// they fail because of a transient error (a connection failure or
+	// a 429, 502, 503 or 504 response).
+	RetryPolicy struct {
+		// Total number of attempts, including the first one. A value
+		// of 1 or less disables retries.
+		MaxAttempts int
+
+		// Delay before the first retry. The delay doubles after each
+		// subsequent attempt, up to MaxBackoff. A delay asked for with
+		// Retry-After is also limited to MaxBackoff.
+		InitialBackoff time.Duration
+		MaxBackoff     time.Duration
+
+		// Randomize each delay by up to this fraction (0 to 1) of
+		// its value.
+		Jitter float64
+	}
+)
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 1 * time.Second,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         0.2,
+}
+
+var retryableStatus = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Only requests that are safe to repeat are retried.
+func isIdempotent(method string) bool {
+	return method == "GET" || method == "HEAD"
+}
+
+func isTransientStatus(statusCode int) bool {
+	for _, this := range retryableStatus {
+		if this == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// Backoff returns the delay before retry number `attempt` (starting
+// at 1).
+func (policy RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := policy.InitialBackoff
+	for i := 1; i < attempt && delay < policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+
+	if policy.Jitter > 0 {
+		delta := float64(delay) * policy.Jitter
+		delay += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+
+	return delay
+}
+
+// Limit a delay asked for by the server (see retryAfter) to
+// MaxBackoff, so that a large Retry-After can't stall us for hours.
+func (policy RetryPolicy) limit(delay time.Duration) time.Duration {
+	if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		return policy.MaxBackoff
+	}
+	if delay < 0 {
+		return 0
+	}
+
+	return delay
+}
+
+// Parse the Retry-After header of a response, which may be either a
+// number of seconds or an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,670 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// ClusterSpec describes the desired state of a cluster. Fields
+	// that are not set are left alone when the spec is applied to
+	// an existing cluster.
+	ClusterSpec struct {
+		Name                     string           `yaml:"name" json:"name"`
+		OpenshiftVersion         string           `yaml:"openshift_version,omitempty" json:"openshift_version,omitempty"`
+		PullSecret               string           `yaml:"pull_secret,omitempty" json:"pull_secret,omitempty"`
+		HighAvailabilityMode     string           `yaml:"high_availability_mode,omitempty" json:"high_availability_mode,omitempty"`
+		OcpReleaseImage          string           `yaml:"ocp_release_image,omitempty" json:"ocp_release_image,omitempty"`
+		BaseDnsDomain            string           `yaml:"base_dns_domain,omitempty" json:"base_dns_domain,omitempty"`
+		ClusterNetworkCidr       string           `yaml:"cluster_network_cidr,omitempty" json:"cluster_network_cidr,omitempty"`
+		ClusterNetworkHostPrefix int              `yaml:"cluster_network_host_prefix,omitempty" json:"cluster_network_host_prefix,omitempty"`
+		ServiceNetworkCidr       string           `yaml:"service_network_cidr,omitempty" json:"service_network_cidr,omitempty"`
+		MachineNetworkCidr       string           `yaml:"machine_network_cidr,omitempty" json:"machine_network_cidr,omitempty"`
+		ClusterNetworks          []ClusterNetwork `yaml:"cluster_networks,omitempty" json:"cluster_networks,omitempty"`
+		ServiceNetworks          []ServiceNetwork `yaml:"service_networks,omitempty" json:"service_networks,omitempty"`
+		MachineNetworks          []MachineNetwork `yaml:"machine_networks,omitempty" json:"machine_networks,omitempty"`
+		ApiVip                   string           `yaml:"api_vip,omitempty" json:"api_vip,omitempty"`
+		IngressVip               string           `yaml:"ingress_vip,omitempty" json:"ingress_vip,omitempty"`
+		VipDhcpAllocation        *bool            `yaml:"vip_dhcp_allocation,omitempty" json:"vip_dhcp_allocation,omitempty"`
+		SshPublicKey             string           `yaml:"ssh_public_key,omitempty" json:"ssh_public_key,omitempty"`
+		HttpProxy                string           `yaml:"http_proxy,omitempty" json:"http_proxy,omitempty"`
+		HttpsProxy               string           `yaml:"https_proxy,omitempty" json:"https_proxy,omitempty"`
+		NoProxy                  string           `yaml:"no_proxy,omitempty" json:"no_proxy,omitempty"`
+		UserManagedNetworking    *bool            `yaml:"user_managed_networking,omitempty" json:"user_managed_networking,omitempty"`
+		AdditionalNtpSource      string           `yaml:"additional_ntp_source,omitempty" json:"additional_ntp_source,omitempty"`
+		Hyperthreading           string           `yaml:"hyperthreading,omitempty" json:"hyperthreading,omitempty"`
+		NetworkType              string           `yaml:"network_type,omitempty" json:"network_type,omitempty"`
+		SchedulableMasters       *bool            `yaml:"schedulable_masters,omitempty" json:"schedulable_masters,omitempty"`
+
+		Hosts     []HostSpec     `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+		Manifests []ManifestSpec `yaml:"manifests,omitempty" json:"manifests,omitempty"`
+	}
+
+	// HostSpec describes the desired state of a host. Hosts are
+	// identified by the MAC address of one of their interfaces or
+	// by their serial number.
+	HostSpec struct {
+		MacAddress   string `yaml:"mac_address,omitempty" json:"mac_address,omitempty"`
+		SerialNumber string `yaml:"serial_number,omitempty" json:"serial_number,omitempty"`
+
+		Hostname         string `yaml:"hostname,omitempty" json:"hostname,omitempty"`
+		Role             string `yaml:"role,omitempty" json:"role,omitempty"`
+		InstallationDisk string `yaml:"installation_disk,omitempty" json:"installation_disk,omitempty"`
+	}
+
+	// ManifestSpec describes a custom manifest. The content is
+	// either given inline or read from Path (relative to the spec
+	// file).
+	ManifestSpec struct {
+		Folder   string `yaml:"folder,omitempty" json:"folder,omitempty"`
+		FileName string `yaml:"file_name" json:"file_name"`
+		Content  string `yaml:"content,omitempty" json:"content,omitempty"`
+		Path     string `yaml:"path,omitempty" json:"path,omitempty"`
+	}
+
+	// ClusterPlan is the set of changes needed to bring a cluster
+	// in line with a ClusterSpec.
+	ClusterPlan struct {
+		Spec *ClusterSpec
+
+		// The existing cluster, or nil if it needs to be created.
+		Cluster *Cluster
+
+		// Set if the cluster needs to be created.
+		Create *ClusterCreateParams
+
+		// Changes to cluster attributes (including host roles and
+		// installation disks).
+		Patch ClusterPatch
+
+		Hostnames []HostName
+		Manifests []ManifestChange
+
+		// Differences that we can't fix, and hosts in the spec
+		// that we couldn't find.
+		Warnings []string
+	}
+
+	ManifestChange struct {
+		Folder   string
+		FileName string
+		Content  []byte
+
+		// True if the manifest exists and needs to be replaced.
+		Replace bool
+	}
+)
+
+// ClusterSpecFromFile reads a YAML or JSON cluster spec. Manifest
+// paths are resolved relative to the directory containing the spec.
+func ClusterSpecFromFile(path string) (*ClusterSpec, error) {
+	var spec ClusterSpec
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for i := range spec.Manifests {
+		manifest := &spec.Manifests[i]
+		if manifest.Path == "" {
+			continue
+		}
+
+		manifestPath := manifest.Path
+		if !filepath.IsAbs(manifestPath) {
+			manifestPath = filepath.Join(filepath.Dir(path), manifestPath)
+		}
+
+		content, err := ioutil.ReadFile(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest.Content = string(content)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &spec, nil
+}
+
+// ClusterSpecFromCluster returns a spec describing an existing
+// cluster, suitable for creating a copy of it with apply. Server-owned
+// attributes (ids, timestamps, status, etc) and the pull secret are
+// not included. Hosts are identified by the MAC address of their first
+// interface (or by serial number if they have no interfaces).
+func ClusterSpecFromCluster(cluster *Cluster) *ClusterSpec {
+	spec := ClusterSpec{
+		Name:                     cluster.Name,
+		OpenshiftVersion:         cluster.OpenshiftVersion,
+		HighAvailabilityMode:     cluster.HighAvailabilityMode,
+		OcpReleaseImage:          cluster.OcpReleaseImage,
+		BaseDnsDomain:            cluster.BaseDNSDomain,
+		ClusterNetworkCidr:       cluster.ClusterNetworkCidr,
+		ClusterNetworkHostPrefix: cluster.ClusterNetworkHostPrefix,
+		ServiceNetworkCidr:       cluster.ServiceNetworkCidr,
+		MachineNetworkCidr:       cluster.MachineNetworkCidr,
+		VipDhcpAllocation:        &cluster.VipDhcpAllocation,
+		SshPublicKey:             strings.TrimSpace(cluster.SshPublicKey),
+		HttpProxy:                cluster.HttpProxy,
+		HttpsProxy:               cluster.HttpsProxy,
+		NoProxy:                  cluster.NoProxy,
+		UserManagedNetworking:    &cluster.UserManagedNetworking,
+		AdditionalNtpSource:      cluster.AdditionalNtpSource,
+		Hyperthreading:           cluster.Hyperthreading,
+		NetworkType:              cluster.NetworkType,
+		SchedulableMasters:       &cluster.SchedulableMasters,
+	}
+
+	// Dual-stack clusters need the network lists; for everything else
+	// the single network fields are enough.
+	if len(cluster.ClusterNetworks) > 1 {
+		spec.ClusterNetworkCidr, spec.ClusterNetworkHostPrefix = "", 0
+		for _, network := range cluster.ClusterNetworks {
+			spec.ClusterNetworks = append(spec.ClusterNetworks, ClusterNetwork{
+				Cidr:       network.Cidr,
+				HostPrefix: network.HostPrefix,
+			})
+		}
+	}
+	if len(cluster.ServiceNetworks) > 1 {
+		spec.ServiceNetworkCidr = ""
+		spec.ServiceNetworks = ServiceNetworksFromCidrs(cluster.ServiceNetworkCidrs())
+	}
+	if len(cluster.MachineNetworks) > 1 {
+		spec.MachineNetworkCidr = ""
+		spec.MachineNetworks = MachineNetworksFromCidrs(cluster.MachineNetworkCidrs())
+	}
+
+	// VIPs allocated by DHCP will be allocated again for the new
+	// cluster.
+	if !cluster.VipDhcpAllocation {
+		spec.ApiVip = cluster.ApiVip
+		spec.IngressVip = cluster.IngressVip
+	}
+
+	for _, host := range cluster.Hosts {
+		hostSpec := HostSpec{
+			Hostname: host.RequestedHostname,
+			Role:     NormalizeHostRole(host.Role),
+		}
+
+		if inventory, err := host.GetInventory(); err == nil {
+			for _, iface := range inventory.Interfaces {
+				if iface.MacAddress != "" {
+					hostSpec.MacAddress = iface.MacAddress
+					break
+				}
+			}
+
+			if hostSpec.MacAddress == "" {
+				hostSpec.SerialNumber = inventory.SystemVendor.SerialNumber
+			}
+		}
+
+		if hostSpec.MacAddress == "" && hostSpec.SerialNumber == "" {
+			log.Warnf("unable to identify host %s; skipping", host.ID)
+			continue
+		}
+
+		spec.Hosts = append(spec.Hosts, hostSpec)
+	}
+
+	sort.Slice(spec.Hosts, func(i, j int) bool {
+		return spec.Hosts[i].Hostname < spec.Hosts[j].Hostname
+	})
+
+	return &spec
+}
+
+// Validate checks the spec for errors that we can detect without
+// talking to the api.
+func (spec *ClusterSpec) Validate() error {
+	if spec.Name == "" {
+		return fmt.Errorf("cluster spec must have a name")
+	}
+
+	if err := spec.CreateParams().Validate(); err != nil {
+		return err
+	}
+
+	for i, host := range spec.Hosts {
+		if host.MacAddress == "" && host.SerialNumber == "" {
+			return fmt.Errorf("host %d: must specify mac_address or serial_number", i)
+		}
+
+		if host.Role != "" && !ValidateHostRole(host.Role) {
+			return fmt.Errorf("host %d: invalid role: %s", i, host.Role)
+		}
+	}
+
+	for i, manifest := range spec.Manifests {
+		if manifest.FileName == "" {
+			return fmt.Errorf("manifest %d: must specify file_name", i)
+		}
+
+		if manifest.Folder == "" {
+			spec.Manifests[i].Folder = "manifests"
+		}
+	}
+
+	return nil
+}
+
+// CreateParams returns the parameters used to create the cluster
+// described by the spec.
+func (spec *ClusterSpec) CreateParams() *ClusterCreateParams {
+	params := ClusterCreateParams{
+		Name:                     spec.Name,
+		OpenshiftVersion:         spec.OpenshiftVersion,
+		PullSecret:               spec.PullSecret,
+		HighAvailabilityMode:     spec.HighAvailabilityMode,
+		OcpReleaseImage:          spec.OcpReleaseImage,
+		BaseDnsDomain:            spec.BaseDnsDomain,
+		ClusterNetworkCidr:       spec.ClusterNetworkCidr,
+		ClusterNetworkHostPrefix: spec.ClusterNetworkHostPrefix,
+		ServiceNetworkCidr:       spec.ServiceNetworkCidr,
+		ClusterNetworks:          spec.ClusterNetworks,
+		ServiceNetworks:          spec.ServiceNetworks,
+		MachineNetworks:          spec.MachineNetworks,
+		IngressVip:               spec.IngressVip,
+		SshPublicKey:             spec.SshPublicKey,
+		HttpProxy:                spec.HttpProxy,
+		HttpsProxy:               spec.HttpsProxy,
+		NoProxy:                  spec.NoProxy,
+		AdditionalNtpSource:      spec.AdditionalNtpSource,
+		Hyperthreading:           spec.Hyperthreading,
+		NetworkType:              spec.NetworkType,
+	}
+
+	if spec.VipDhcpAllocation != nil {
+		params.VipDhcpAllocation = *spec.VipDhcpAllocation
+	}
+	if spec.UserManagedNetworking != nil {
+		params.UserManagedNetworking = *spec.UserManagedNetworking
+	}
+	if spec.SchedulableMasters != nil {
+		params.SchedulableMasters = *spec.SchedulableMasters
+	}
+
+	return &params
+}
+
+// Empty returns true if the plan doesn't change anything.
+func (plan *ClusterPlan) Empty() bool {
+	return plan.Create == nil &&
+		len(plan.Patch) == 0 &&
+		len(plan.Hostnames) == 0 &&
+		len(plan.Manifests) == 0
+}
+
+// InSync returns true if the cluster already matches the spec: the
+// plan doesn't change anything, and there are no differences (reported
+// as warnings) that the plan can't fix.
+func (plan *ClusterPlan) InSync() bool {
+	return plan.Empty() && len(plan.Warnings) == 0
+}
+
+func (patch ClusterPatch) setString(key, current, desired string) {
+	if desired != "" && desired != current {
+		patch[key] = desired
+	}
+}
+
+func (patch ClusterPatch) setInt(key string, current, desired int) {
+	if desired != 0 && desired != current {
+		patch[key] = desired
+	}
+}
+
+// Set a network list if the CIDRs differ from the current ones.
+func (patch ClusterPatch) setNetworks(key string, current, desired []string, value interface{}) {
+	if len(desired) == 0 {
+		return
+	}
+
+	if len(current) == len(desired) {
+		same := true
+		for i := range desired {
+			if current[i] != desired[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+
+	patch[key] = value
+}
+
+// Cluster networks also differ if a host prefix in the spec differs.
+func (patch ClusterPatch) setClusterNetworks(cluster *Cluster, desired []ClusterNetwork) {
+	patch.setNetworks("cluster_networks", cluster.ClusterNetworkCidrs(), clusterNetworkCidrs(desired), desired)
+
+	current := cluster.ClusterNetworks
+	if len(current) == 0 && cluster.ClusterNetworkCidr != "" {
+		current = []ClusterNetwork{{
+			Cidr:       cluster.ClusterNetworkCidr,
+			HostPrefix: cluster.ClusterNetworkHostPrefix,
+		}}
+	}
+
+	if len(current) != len(desired) {
+		return
+	}
+
+	for i := range desired {
+		if desired[i].HostPrefix != 0 && desired[i].HostPrefix != current[i].HostPrefix {
+			patch["cluster_networks"] = desired
+		}
+	}
+}
+
+func (patch ClusterPatch) setBool(key string, current bool, desired *bool) {
+	if desired != nil && *desired != current {
+		patch[key] = *desired
+	}
+}
+
+// Find the host matching a host spec. It is an error if more than
+// one host matches.
+func matchHostSpec(hostSpec *HostSpec, hosts []Host) (*Host, error) {
+	var found []Host
+
+	for _, host := range hosts {
+		inventory, err := host.GetInventory()
+		if err != nil {
+			continue
+		}
+
+		if hostSpec.MacAddress != "" && !inventory.HasMacAddress(hostSpec.MacAddress) {
+			continue
+		}
+
+		if hostSpec.SerialNumber != "" && inventory.SystemVendor.SerialNumber != hostSpec.SerialNumber {
+			continue
+		}
+
+		found = append(found, host)
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, fmt.Errorf("more than one host matches %s", hostSpec.describe())
+	}
+}
+
+func (hostSpec *HostSpec) describe() string {
+	var keys []string
+
+	if hostSpec.MacAddress != "" {
+		keys = append(keys, fmt.Sprintf("mac_address=%s", hostSpec.MacAddress))
+	}
+	if hostSpec.SerialNumber != "" {
+		keys = append(keys, fmt.Sprintf("serial_number=%s", hostSpec.SerialNumber))
+	}
+
+	return strings.Join(keys, ",")
+}
+
+func (client *ApiClient) PlanCluster(spec *ClusterSpec) (*ClusterPlan, error) {
+	return client.PlanClusterCtx(context.Background(), spec)
+}
+
+// PlanClusterCtx compares a spec with the live cluster of the same
+// name and returns the changes needed to make the cluster match the
+// spec.
+func (client *ApiClient) PlanClusterCtx(ctx context.Context, spec *ClusterSpec) (*ClusterPlan, error) {
+	clusters, err := client.ListClustersCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusterid string
+	for _, cluster := range clusters {
+		if cluster.Name == spec.Name {
+			if clusterid != "" {
+				return nil, fmt.Errorf("more than one cluster named %s", spec.Name)
+			}
+			clusterid = cluster.ID
+		}
+	}
+
+	if clusterid == "" {
+		log.Debugf("cluster %s does not exist", spec.Name)
+		return &ClusterPlan{
+			Spec:   spec,
+			Create: spec.CreateParams(),
+		}, nil
+	}
+
+	cluster, err := client.GetClusterCtx(ctx, clusterid)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.planForCluster(ctx, spec, cluster)
+}
+
+func (client *ApiClient) planForCluster(ctx context.Context, spec *ClusterSpec, cluster *Cluster) (*ClusterPlan, error) {
+	plan := ClusterPlan{
+		Spec:    spec,
+		Cluster: cluster,
+		Patch:   ClusterPatch{},
+	}
+
+	// These can't be changed once the cluster exists.
+	if spec.OpenshiftVersion != "" && !strings.HasPrefix(cluster.OpenshiftVersion, spec.OpenshiftVersion) {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"openshift_version is %s (want %s) and cannot be changed",
+			cluster.OpenshiftVersion, spec.OpenshiftVersion))
+	}
+	if spec.HighAvailabilityMode != "" && cluster.HighAvailabilityMode != spec.HighAvailabilityMode {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"high_availability_mode is %s (want %s) and cannot be changed",
+			cluster.HighAvailabilityMode, spec.HighAvailabilityMode))
+	}
+
+	plan.Patch.setString("base_dns_domain", cluster.BaseDNSDomain, spec.BaseDnsDomain)
+	plan.Patch.setString("cluster_network_cidr", cluster.ClusterNetworkCidr, spec.ClusterNetworkCidr)
+	plan.Patch.setInt("cluster_network_host_prefix", cluster.ClusterNetworkHostPrefix, spec.ClusterNetworkHostPrefix)
+	plan.Patch.setString("service_network_cidr", cluster.ServiceNetworkCidr, spec.ServiceNetworkCidr)
+	plan.Patch.setString("machine_network_cidr", cluster.MachineNetworkCidr, spec.MachineNetworkCidr)
+	plan.Patch.setClusterNetworks(cluster, spec.ClusterNetworks)
+	plan.Patch.setNetworks("service_networks",
+		cluster.ServiceNetworkCidrs(), serviceNetworkCidrs(spec.ServiceNetworks), spec.ServiceNetworks)
+	plan.Patch.setNetworks("machine_networks",
+		cluster.MachineNetworkCidrs(), machineNetworkCidrs(spec.MachineNetworks), spec.MachineNetworks)
+	plan.Patch.setString("api_vip", cluster.ApiVip, spec.ApiVip)
+	plan.Patch.setString("ingress_vip", cluster.IngressVip, spec.IngressVip)
+	plan.Patch.setBool("vip_dhcp_allocation", cluster.VipDhcpAllocation, spec.VipDhcpAllocation)
+	plan.Patch.setString("ssh_public_key",
+		strings.TrimSpace(cluster.SshPublicKey), strings.TrimSpace(spec.SshPublicKey))
+	plan.Patch.setString("http_proxy", cluster.HttpProxy, spec.HttpProxy)
+	plan.Patch.setString("https_proxy", cluster.HttpsProxy, spec.HttpsProxy)
+	plan.Patch.setString("no_proxy", cluster.NoProxy, spec.NoProxy)
+	plan.Patch.setBool("user_managed_networking", cluster.UserManagedNetworking, spec.UserManagedNetworking)
+	plan.Patch.setString("additional_ntp_source", cluster.AdditionalNtpSource, spec.AdditionalNtpSource)
+	plan.Patch.setString("hyperthreading", cluster.Hyperthreading, spec.Hyperthreading)
+	plan.Patch.setString("network_type", cluster.NetworkType, spec.NetworkType)
+	plan.Patch.setBool("schedulable_masters", cluster.SchedulableMasters, spec.SchedulableMasters)
+
+	// Setting static VIPs only makes sense if we're not using DHCP
+	// allocation.
+	if _, ok := plan.Patch["api_vip"]; ok && spec.VipDhcpAllocation == nil && cluster.VipDhcpAllocation {
+		plan.Patch["vip_dhcp_allocation"] = false
+	}
+
+	var hostRoles []HostRole
+	var hostDisks []HostDisksConfig
+
+	for i := range spec.Hosts {
+		hostSpec := &spec.Hosts[i]
+
+		host, err := matchHostSpec(hostSpec, cluster.Hosts)
+		if err != nil {
+			return nil, err
+		}
+		if host == nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+				"no host matching %s", hostSpec.describe()))
+			continue
+		}
+
+		if hostSpec.Hostname != "" && host.RequestedHostname != hostSpec.Hostname {
+			plan.Hostnames = append(plan.Hostnames, HostName{
+				ID:       host.ID,
+				HostName: hostSpec.Hostname,
+			})
+		}
+
+		if hostSpec.Role != "" && NormalizeHostRole(host.Role) != hostSpec.Role {
+			hostRoles = append(hostRoles, HostRole{
+				ID:   host.ID,
+				Role: hostSpec.Role,
+			})
+		}
+
+		if hostSpec.InstallationDisk != "" {
+			inventory, err := host.GetInventory()
+			if err != nil {
+				return nil, err
+			}
+
+			disk, err := inventory.FindDisk(hostSpec.InstallationDisk)
+			if err != nil {
+				return nil, fmt.Errorf("host %s: %w", hostSpec.describe(), err)
+			}
+
+			if host.InstallationDiskID != disk.ID {
+				hostDisks = append(hostDisks, HostDisksConfig{
+					ID:          host.ID,
+					DisksConfig: []DiskConfig{{ID: disk.ID, Role: "install"}},
+				})
+			}
+		}
+	}
+
+	if len(hostRoles) > 0 {
+		plan.Patch["hosts_roles"] = hostRoles
+	}
+	if len(hostDisks) > 0 {
+		plan.Patch["disks_selected_config"] = hostDisks
+	}
+
+	if len(spec.Manifests) > 0 {
+		existing, err := client.ListManifestsCtx(ctx, cluster.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, manifest := range spec.Manifests {
+			change := ManifestChange{
+				Folder:   manifest.Folder,
+				FileName: manifest.FileName,
+				Content:  []byte(manifest.Content),
+			}
+
+			for _, have := range existing {
+				if have.Folder == manifest.Folder && have.FileName == manifest.FileName {
+					change.Replace = true
+					break
+				}
+			}
+
+			if change.Replace {
+				content, err := client.GetManifestCtx(ctx, cluster.ID, manifest.Folder, manifest.FileName)
+				if err != nil {
+					return nil, err
+				}
+				if bytes.Equal(content, change.Content) {
+					continue
+				}
+			}
+
+			plan.Manifests = append(plan.Manifests, change)
+		}
+	}
+
+	return &plan, nil
+}
+
+func (client *ApiClient) ApplyClusterPlan(plan *ClusterPlan) (*Cluster, error) {
+	return client.ApplyClusterPlanCtx(context.Background(), plan)
+}
+
+// ApplyClusterPlanCtx makes the changes described by a plan. If the
+// plan creates a cluster, we plan and apply the remaining changes
+// (VIPs, manifests, etc) against the new cluster.
+func (client *ApiClient) ApplyClusterPlanCtx(ctx context.Context, plan *ClusterPlan) (*Cluster, error) {
+	cluster := plan.Cluster
+
+	if plan.Create != nil {
+		log.Infof("creating cluster %s", plan.Create.Name)
+		created, err := client.CreateClusterCtx(ctx, plan.Create)
+		if err != nil {
+			return nil, err
+		}
+
+		plan, err = client.planForCluster(ctx, plan.Spec, created)
+		if err != nil {
+			return nil, err
+		}
+		cluster = created
+	}
+
+	if len(plan.Patch) > 0 {
+		log.Infof("updating cluster %s", cluster.Name)
+		patched, err := client.PatchClusterCtx(ctx, cluster.ID, plan.Patch)
+		if err != nil {
+			return nil, err
+		}
+		cluster = patched
+	}
+
+	if len(plan.Hostnames) > 0 {
+		log.Infof("setting hostnames for %d hosts", len(plan.Hostnames))
+		if err := client.SetHostnamesCtx(ctx, cluster.ID, plan.Hostnames); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, manifest := range plan.Manifests {
+		if manifest.Replace {
+			log.Infof("replacing manifest %s/%s", manifest.Folder, manifest.FileName)
+			if err := client.DeleteManifestCtx(ctx, cluster.ID, manifest.Folder, manifest.FileName); err != nil {
+				return nil, err
+			}
+		} else {
+			log.Infof("creating manifest %s/%s", manifest.Folder, manifest.FileName)
+		}
+
+		if err := client.CreateManifestCtx(
+			ctx, cluster.ID, manifest.Folder, manifest.FileName, manifest.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	return cluster, nil
+}
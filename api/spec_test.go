@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClusterSpecRoundTrip(t *testing.T) {
+	cluster := Cluster{
+		ID:                   testClusterID,
+		Name:                 "lab",
+		OpenshiftVersion:     "4.8.2",
+		HighAvailabilityMode: "Full",
+		BaseDNSDomain:        "example.com",
+		Hosts: []Host{
+			{ID: "h1", Role: "bootstrap", Inventory: `{"interfaces": [{"mac_address": "52:54:00:00:00:01"}]}`},
+			{ID: "h2", Role: "master", Inventory: `{"interfaces": [{"mac_address": "52:54:00:00:00:02"}]}`},
+			{ID: "h3", Role: "", Inventory: `{"interfaces": [{"mac_address": "52:54:00:00:00:03"}]}`},
+			{ID: "h4", Role: "worker", Inventory: `{"interfaces": [{"mac_address": "52:54:00:00:00:04"}]}`},
+		},
+	}
+
+	spec := ClusterSpecFromCluster(&cluster)
+
+	var roles []string
+	for _, host := range spec.Hosts {
+		roles = append(roles, host.Role)
+	}
+	want := []string{"master", "master", "auto-assign", "worker"}
+	if len(roles) != len(want) {
+		t.Fatalf("got roles %v, want %v", roles, want)
+	}
+	for i := range want {
+		if roles[i] != want[i] {
+			t.Errorf("got roles %v, want %v", roles, want)
+			break
+		}
+	}
+
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("exported spec is invalid: %v", err)
+	}
+
+	var client ApiClient
+	plan, err := client.planForCluster(context.Background(), spec, &cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !plan.InSync() {
+		t.Errorf("exported spec doesn't match the cluster: patch %v, warnings %v", plan.Patch, plan.Warnings)
+	}
+}
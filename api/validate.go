@@ -0,0 +1,456 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Parse a CIDR, returning an error that names the field.
+func parseCidr(name, value string) (*net.IPNet, error) {
+	_, ipnet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: not a valid CIDR", name, value)
+	}
+
+	return ipnet, nil
+}
+
+// Parse an IP address, returning an error that names the field.
+func parseIP(name, value string) (net.IP, error) {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid %s %q: not a valid IP address", name, value)
+	}
+
+	return ip, nil
+}
+
+// Check that a host prefix makes sense for the given cluster network:
+// it must be longer than the network prefix and no longer than the
+// address. If we don't know the cluster network, we can only check
+// that it is a plausible prefix length.
+func validateHostPrefix(hostPrefix int, clusterNetwork *net.IPNet) error {
+	minPrefix, maxPrefix := 1, 128
+
+	if clusterNetwork != nil {
+		ones, bits := clusterNetwork.Mask.Size()
+		minPrefix, maxPrefix = ones+1, bits
+	}
+
+	if hostPrefix < minPrefix || hostPrefix > maxPrefix {
+		return fmt.Errorf("invalid cluster network host prefix %d: must be between %d and %d",
+			hostPrefix, minPrefix, maxPrefix)
+	}
+
+	return nil
+}
+
+// NetworkSettings is the network configuration of a cluster, used to
+// check VIPs and CIDRs before sending them to the api. Dual-stack
+// clusters have two networks of each type.
+type NetworkSettings struct {
+	ClusterNetworks []string
+	ServiceNetworks []string
+	MachineNetworks []string
+	ApiVip          string
+	IngressVip      string
+
+	// The VIPs the cluster has now. On an installed cluster these
+	// are held by keepalived on one of the hosts, so finding them on
+	// a host doesn't mean they are in use by something else.
+	CurrentVips []string
+}
+
+// NetworkSettings returns the current network configuration of the
+// cluster.
+func (cluster *Cluster) NetworkSettings() *NetworkSettings {
+	return &NetworkSettings{
+		ClusterNetworks: cluster.ClusterNetworkCidrs(),
+		ServiceNetworks: cluster.ServiceNetworkCidrs(),
+		MachineNetworks: cluster.MachineNetworkCidrs(),
+		ApiVip:          cluster.ApiVip,
+		IngressVip:      cluster.IngressVip,
+		CurrentVips:     currentVips(cluster),
+	}
+}
+
+// Return the VIPs the cluster has now.
+func currentVips(cluster *Cluster) []string {
+	var vips []string
+
+	for _, vip := range []string{cluster.ApiVip, cluster.IngressVip} {
+		if vip != "" {
+			vips = append(vips, vip)
+		}
+	}
+
+	return vips
+}
+
+// Return true if ip is one of the given addresses.
+func containsIP(addrs []string, ip net.IP) bool {
+	for _, addr := range addrs {
+		if other := net.ParseIP(addr); other != nil && other.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return the host (if any) that has the given address on one of its
+// interfaces.
+func hostWithAddress(hosts []Host, ip net.IP) *Host {
+	for i := range hosts {
+		inventory, err := hosts[i].GetInventory()
+		if err != nil {
+			continue
+		}
+
+		for _, iface := range inventory.Interfaces {
+			addrs := append(append([]string{}, iface.Ipv4Addresses...), iface.Ipv6Addresses...)
+			for _, addr := range addrs {
+				hostIP, _, err := net.ParseCIDR(addr)
+				if err != nil {
+					hostIP = net.ParseIP(addr)
+				}
+				if hostIP != nil && hostIP.Equal(ip) {
+					return &hosts[i]
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the cluster, service and machine networks are
+// valid CIDRs that don't overlap, and that the VIPs are distinct
+// addresses in a machine network that aren't in use by any of the
+// given hosts (other than as one of the cluster's current VIPs).
+func (settings *NetworkSettings) Validate(hosts []Host) error {
+	type namedNetwork struct {
+		name  string
+		ipnet *net.IPNet
+	}
+
+	var networks []namedNetwork
+	var machineNetworks []*net.IPNet
+
+	for _, networkType := range []struct {
+		name  string
+		cidrs []string
+	}{
+		{"cluster network", settings.ClusterNetworks},
+		{"service network", settings.ServiceNetworks},
+		{"machine network", settings.MachineNetworks},
+	} {
+		var parsed []*net.IPNet
+
+		for _, cidr := range networkType.cidrs {
+			ipnet, err := parseCidr(networkType.name+" cidr", cidr)
+			if err != nil {
+				return err
+			}
+
+			for _, other := range networks {
+				if ipnet.Contains(other.ipnet.IP) || other.ipnet.Contains(ipnet.IP) {
+					return fmt.Errorf("%s %s overlaps %s %s",
+						networkType.name, ipnet, other.name, other.ipnet)
+				}
+			}
+
+			networks = append(networks, namedNetwork{networkType.name, ipnet})
+			parsed = append(parsed, ipnet)
+		}
+
+		if err := validateNetworkFamilies(networkType.name, parsed); err != nil {
+			return err
+		}
+
+		if networkType.name == "machine network" {
+			machineNetworks = parsed
+		}
+	}
+
+	var vips []net.IP
+
+	for _, vip := range []struct {
+		name, value string
+	}{
+		{"api vip", settings.ApiVip},
+		{"ingress vip", settings.IngressVip},
+	} {
+		if vip.value == "" {
+			continue
+		}
+
+		ip, err := parseIP(vip.name, vip.value)
+		if err != nil {
+			return err
+		}
+
+		if len(machineNetworks) > 0 {
+			found := false
+			for _, machineNetwork := range machineNetworks {
+				if machineNetwork.Contains(ip) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s %s is not in the machine network %v",
+					vip.name, ip, settings.MachineNetworks)
+			}
+		}
+
+		host := hostWithAddress(hosts, ip)
+		if host != nil && !containsIP(settings.CurrentVips, ip) {
+			name := host.RequestedHostname
+			if name == "" {
+				name = host.ID
+			}
+			return fmt.Errorf("%s %s is already in use by host %s", vip.name, ip, name)
+		}
+
+		vips = append(vips, ip)
+	}
+
+	if len(vips) == 2 && vips[0].Equal(vips[1]) {
+		return fmt.Errorf("api vip and ingress vip must be different (both are %s)", vips[0])
+	}
+
+	return nil
+}
+
+// Check the host prefix of each cluster network.
+func validateClusterNetworks(networks []ClusterNetwork) error {
+	for _, network := range networks {
+		ipnet, err := parseCidr("cluster network cidr", network.Cidr)
+		if err != nil {
+			return err
+		}
+
+		if network.HostPrefix != 0 {
+			if err := validateHostPrefix(network.HostPrefix, ipnet); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks cluster creation parameters for errors that we can
+// detect without talking to the api.
+func (params *ClusterCreateParams) Validate() error {
+	var clusterNetwork *net.IPNet
+	var err error
+
+	if params.Name == "" {
+		return fmt.Errorf("a cluster name is required")
+	}
+
+	if params.HighAvailabilityMode != "" && !ValidateHighAvailabilityMode(params.HighAvailabilityMode) {
+		return fmt.Errorf("invalid high availability mode %q: must be one of %v",
+			params.HighAvailabilityMode, supportedHighAvailabilityModes)
+	}
+
+	if params.Hyperthreading != "" && !ValidateHyperthreading(params.Hyperthreading) {
+		return fmt.Errorf("invalid hyperthreading %q: must be one of %v",
+			params.Hyperthreading, supportedHyperthreading)
+	}
+
+	if params.NetworkType != "" && !ValidateNetworkType(params.NetworkType) {
+		return fmt.Errorf("invalid network type %q: must be one of %v",
+			params.NetworkType, supportedNetworkTypes)
+	}
+
+	if params.ClusterNetworkCidr != "" {
+		clusterNetwork, err = parseCidr("cluster network cidr", params.ClusterNetworkCidr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if params.ClusterNetworkHostPrefix != 0 {
+		if err := validateHostPrefix(params.ClusterNetworkHostPrefix, clusterNetwork); err != nil {
+			return err
+		}
+	}
+
+	if err := validateClusterNetworks(params.ClusterNetworks); err != nil {
+		return err
+	}
+
+	return params.NetworkSettings().Validate(nil)
+}
+
+// NetworkSettings returns the network configuration of the cluster
+// that would be created. The network lists take precedence over the
+// single network fields.
+func (params *ClusterCreateParams) NetworkSettings() *NetworkSettings {
+	cluster := Cluster{
+		ClusterNetworkCidr: params.ClusterNetworkCidr,
+		ClusterNetworks:    params.ClusterNetworks,
+		ServiceNetworkCidr: params.ServiceNetworkCidr,
+		ServiceNetworks:    params.ServiceNetworks,
+		MachineNetworks:    params.MachineNetworks,
+		IngressVip:         params.IngressVip,
+	}
+
+	settings := cluster.NetworkSettings()
+	settings.CurrentVips = nil
+
+	return settings
+}
+
+// Validate checks cluster update parameters for errors that we can
+// detect without talking to the api.
+func (params *ClusterUpdateParams) Validate() error {
+	var clusterNetwork *net.IPNet
+	var err error
+
+	if params.Name != nil && *params.Name == "" {
+		return fmt.Errorf("cluster name cannot be empty")
+	}
+
+	if params.Hyperthreading != nil && !ValidateHyperthreading(*params.Hyperthreading) {
+		return fmt.Errorf("invalid hyperthreading %q: must be one of %v",
+			*params.Hyperthreading, supportedHyperthreading)
+	}
+
+	if params.NetworkType != nil && !ValidateNetworkType(*params.NetworkType) {
+		return fmt.Errorf("invalid network type %q: must be one of %v",
+			*params.NetworkType, supportedNetworkTypes)
+	}
+
+	if params.ClusterNetworkCidr != nil {
+		clusterNetwork, err = parseCidr("cluster network cidr", *params.ClusterNetworkCidr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if params.ClusterNetworkHostPrefix != nil {
+		if err := validateHostPrefix(*params.ClusterNetworkHostPrefix, clusterNetwork); err != nil {
+			return err
+		}
+	}
+
+	if err := validateClusterNetworks(params.ClusterNetworks); err != nil {
+		return err
+	}
+
+	for name, value := range map[string]*string{
+		"service network cidr": params.ServiceNetworkCidr,
+		"machine network cidr": params.MachineNetworkCidr,
+	} {
+		if value == nil {
+			continue
+		}
+		if _, err := parseCidr(name, *value); err != nil {
+			return err
+		}
+	}
+
+	for name, value := range map[string]*string{
+		"api vip":     params.ApiVip,
+		"ingress vip": params.IngressVip,
+	} {
+		if value == nil {
+			continue
+		}
+		if _, err := parseIP(name, *value); err != nil {
+			return err
+		}
+	}
+
+	for _, hostRole := range params.HostsRoles {
+		if !ValidateHostRole(hostRole.Role) {
+			return fmt.Errorf("invalid role %q for host %s: must be one of %v",
+				hostRole.Role, hostRole.ID, supportedHostRoles)
+		}
+	}
+
+	return nil
+}
+
+// NetworkSettings returns the network configuration the cluster would
+// have after applying the update, or nil if the update doesn't change
+// the network configuration.
+func (params *ClusterUpdateParams) NetworkSettings(cluster *Cluster) *NetworkSettings {
+	settings := cluster.NetworkSettings()
+	changed := false
+
+	for _, field := range []struct {
+		value   *string
+		current *[]string
+	}{
+		{params.ClusterNetworkCidr, &settings.ClusterNetworks},
+		{params.ServiceNetworkCidr, &settings.ServiceNetworks},
+		{params.MachineNetworkCidr, &settings.MachineNetworks},
+	} {
+		if field.value != nil {
+			*field.current = []string{*field.value}
+			changed = true
+		}
+	}
+
+	if len(params.ClusterNetworks) > 0 {
+		settings.ClusterNetworks = clusterNetworkCidrs(params.ClusterNetworks)
+		changed = true
+	}
+	if len(params.ServiceNetworks) > 0 {
+		settings.ServiceNetworks = serviceNetworkCidrs(params.ServiceNetworks)
+		changed = true
+	}
+	if len(params.MachineNetworks) > 0 {
+		settings.MachineNetworks = machineNetworkCidrs(params.MachineNetworks)
+		changed = true
+	}
+
+	for _, field := range []struct {
+		value   *string
+		current *string
+	}{
+		{params.ApiVip, &settings.ApiVip},
+		{params.IngressVip, &settings.IngressVip},
+	} {
+		if field.value != nil {
+			*field.current = *field.value
+			changed = true
+		}
+	}
+
+	// VIPs allocated by DHCP are the api's problem.
+	if params.VipDhcpAllocation != nil && *params.VipDhcpAllocation {
+		settings.ApiVip, settings.IngressVip = "", ""
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return settings
+}
+
+// ClusterCreateParamsFromFile reads cluster creation parameters from a
+// YAML or JSON file, using the api field names.
+func ClusterCreateParamsFromFile(path string) (*ClusterCreateParams, error) {
+	var params ClusterCreateParams
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, &params); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &params, nil
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"net"
+	"testing"
+)
+
+func stringPtr(s string) *string { return &s }
+
+func intPtr(i int) *int { return &i }
+
+func TestParseCidr(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"10.128.0.0/14", "10.128.0.0/14", true},
+		{"10.128.1.2/14", "10.128.0.0/14", true},
+		{"fd01::/48", "fd01::/48", true},
+		{"10.128.0.0", "", false},
+		{"10.128.0.0/33", "", false},
+		{"not-a-cidr", "", false},
+		{"", "", false},
+	} {
+		ipnet, err := parseCidr("cluster network cidr", tc.value)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.value)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+			continue
+		}
+
+		if ipnet.String() != tc.want {
+			t.Errorf("%q: got %s, want %s", tc.value, ipnet, tc.want)
+		}
+	}
+}
+
+func TestParseIP(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		ok    bool
+	}{
+		{"192.168.10.5", true},
+		{"fd00::5", true},
+		{"192.168.10.256", false},
+		{"192.168.10.0/24", false},
+		{"api.example.com", false},
+		{"", false},
+	} {
+		_, err := parseIP("api vip", tc.value)
+		if tc.ok && err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%q: expected an error", tc.value)
+		}
+	}
+}
+
+func TestValidateHostPrefix(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("10.128.0.0/14")
+	_, v6, _ := net.ParseCIDR("fd01::/48")
+
+	for _, tc := range []struct {
+		prefix  int
+		network *net.IPNet
+		ok      bool
+	}{
+		{23, v4, true},
+		{15, v4, true},
+		{32, v4, true},
+		{14, v4, false},
+		{33, v4, false},
+		{64, v6, true},
+		{48, v6, false},
+		{64, nil, true},
+		{128, nil, true},
+		{0, nil, false},
+		{129, nil, false},
+	} {
+		err := validateHostPrefix(tc.prefix, tc.network)
+		if tc.ok && err != nil {
+			t.Errorf("%d in %v: unexpected error: %v", tc.prefix, tc.network, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%d in %v: expected an error", tc.prefix, tc.network)
+		}
+	}
+}
+
+func TestClusterCreateParamsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		params ClusterCreateParams
+		ok     bool
+	}{
+		{"minimal", ClusterCreateParams{Name: "lab"}, true},
+		{"full", ClusterCreateParams{
+			Name:                     "lab",
+			HighAvailabilityMode:     "Full",
+			Hyperthreading:           "all",
+			NetworkType:              "OVNKubernetes",
+			ClusterNetworkCidr:       "10.128.0.0/14",
+			ClusterNetworkHostPrefix: 23,
+			ServiceNetworkCidr:       "172.30.0.0/16",
+		}, true},
+		{"dual stack", ClusterCreateParams{
+			Name: "lab",
+			ClusterNetworks: []ClusterNetwork{
+				{Cidr: "10.128.0.0/14", HostPrefix: 23},
+				{Cidr: "fd01::/48", HostPrefix: 64},
+			},
+		}, true},
+		{"no name", ClusterCreateParams{}, false},
+		{"bad ha mode", ClusterCreateParams{Name: "lab", HighAvailabilityMode: "Partial"}, false},
+		{"bad hyperthreading", ClusterCreateParams{Name: "lab", Hyperthreading: "some"}, false},
+		{"bad network type", ClusterCreateParams{Name: "lab", NetworkType: "Calico"}, false},
+		{"bad cluster network", ClusterCreateParams{Name: "lab", ClusterNetworkCidr: "10.128.0.0"}, false},
+		{"host prefix too short", ClusterCreateParams{
+			Name:                     "lab",
+			ClusterNetworkCidr:       "10.128.0.0/14",
+			ClusterNetworkHostPrefix: 12,
+		}, false},
+		{"host prefix too long", ClusterCreateParams{Name: "lab", ClusterNetworkHostPrefix: 200}, false},
+		{"bad dual stack host prefix", ClusterCreateParams{
+			Name: "lab",
+			ClusterNetworks: []ClusterNetwork{
+				{Cidr: "fd01::/48", HostPrefix: 40},
+			},
+		}, false},
+		{"bad service network", ClusterCreateParams{Name: "lab", ServiceNetworkCidr: "172.30.0.0/99"}, false},
+		{"bad ingress vip", ClusterCreateParams{Name: "lab", IngressVip: "192.168.10.300"}, false},
+	} {
+		err := tc.params.Validate()
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestClusterUpdateParamsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		params ClusterUpdateParams
+		ok     bool
+	}{
+		{"empty", ClusterUpdateParams{}, true},
+		{"full", ClusterUpdateParams{
+			Name:                     stringPtr("lab"),
+			Hyperthreading:           stringPtr("none"),
+			NetworkType:              stringPtr("OpenShiftSDN"),
+			ClusterNetworkCidr:       stringPtr("10.128.0.0/14"),
+			ClusterNetworkHostPrefix: intPtr(23),
+			ServiceNetworkCidr:       stringPtr("172.30.0.0/16"),
+			MachineNetworkCidr:       stringPtr("192.168.10.0/24"),
+			ApiVip:                   stringPtr("192.168.10.5"),
+			IngressVip:               stringPtr("192.168.10.6"),
+			HostsRoles:               []HostRole{{ID: "h1", Role: "master"}},
+		}, true},
+		{"empty name", ClusterUpdateParams{Name: stringPtr("")}, false},
+		{"bad hyperthreading", ClusterUpdateParams{Hyperthreading: stringPtr("some")}, false},
+		{"bad network type", ClusterUpdateParams{NetworkType: stringPtr("Calico")}, false},
+		{"bad cluster network", ClusterUpdateParams{ClusterNetworkCidr: stringPtr("10.128.0.0")}, false},
+		{"host prefix too short", ClusterUpdateParams{
+			ClusterNetworkCidr:       stringPtr("10.128.0.0/14"),
+			ClusterNetworkHostPrefix: intPtr(8),
+		}, false},
+		{"bad dual stack host prefix", ClusterUpdateParams{
+			ClusterNetworks: []ClusterNetwork{{Cidr: "10.128.0.0/14", HostPrefix: 33}},
+		}, false},
+		{"bad service network", ClusterUpdateParams{ServiceNetworkCidr: stringPtr("172.30.0.0")}, false},
+		{"bad machine network", ClusterUpdateParams{MachineNetworkCidr: stringPtr("192.168.10/24")}, false},
+		{"bad api vip", ClusterUpdateParams{ApiVip: stringPtr("api")}, false},
+		{"bad ingress vip", ClusterUpdateParams{IngressVip: stringPtr("192.168.10.0/24")}, false},
+		{"bad role", ClusterUpdateParams{HostsRoles: []HostRole{{ID: "h1", Role: "bootstrap"}}}, false},
+	} {
+		err := tc.params.Validate()
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestNetworkSettingsValidate(t *testing.T) {
+	hosts := []Host{
+		{
+			ID:                "h1",
+			RequestedHostname: "node-a",
+			Inventory:         `{"interfaces": [{"ipv4_addresses": ["192.168.10.21/24", "192.168.10.5/24"]}]}`,
+		},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		settings NetworkSettings
+		ok       bool
+	}{
+		{"valid", NetworkSettings{
+			ClusterNetworks: []string{"10.128.0.0/14"},
+			ServiceNetworks: []string{"172.30.0.0/16"},
+			MachineNetworks: []string{"192.168.10.0/24"},
+			ApiVip:          "192.168.10.6",
+			IngressVip:      "192.168.10.7",
+		}, true},
+		{"overlapping networks", NetworkSettings{
+			ClusterNetworks: []string{"10.128.0.0/14"},
+			ServiceNetworks: []string{"10.130.0.0/16"},
+		}, false},
+		{"vip outside machine network", NetworkSettings{
+			MachineNetworks: []string{"192.168.10.0/24"},
+			ApiVip:          "192.168.20.6",
+		}, false},
+		{"same vips", NetworkSettings{
+			ApiVip:     "192.168.10.6",
+			IngressVip: "192.168.10.6",
+		}, false},
+		{"vip used by host", NetworkSettings{
+			ApiVip: "192.168.10.21",
+		}, false},
+		{"current vip held by host", NetworkSettings{
+			ApiVip:      "192.168.10.5",
+			CurrentVips: []string{"192.168.10.5"},
+		}, true},
+		{"new vip held by host", NetworkSettings{
+			ApiVip:      "192.168.10.21",
+			CurrentVips: []string{"192.168.10.5"},
+		}, false},
+	} {
+		err := tc.settings.Validate(hosts)
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// ParseValidationsInfo decodes a validations_info string. An empty
+// string (e.g. for a host that hasn't been validated yet) is an empty
+// ValidationsInfo.
+func ParseValidationsInfo(data string) (ValidationsInfo, error) {
+	info := ValidationsInfo{}
+
+	if data == "" {
+		return info, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		return nil, err
+	}
+
+	for category, validations := range info {
+		for i := range validations {
+			validations[i].Category = category
+		}
+	}
+
+	return info, nil
+}
+
+func (cluster *Cluster) GetValidationsInfo() (ValidationsInfo, error) {
+	return ParseValidationsInfo(cluster.ValidationsInfo)
+}
+
+func (host *Host) GetValidationsInfo() (ValidationsInfo, error) {
+	return ParseValidationsInfo(host.ValidationsInfo)
+}
+
+// Categories returns the validation categories in sorted order.
+func (info ValidationsInfo) Categories() []string {
+	var categories []string
+
+	for category := range info {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	return categories
+}
+
+// ByCategory returns the validations in the given category.
+func (info ValidationsInfo) ByCategory(category string) []Validation {
+	return info[category]
+}
+
+// All returns every validation, ordered by category and then id.
+func (info ValidationsInfo) All() []Validation {
+	var validations []Validation
+
+	for _, category := range info.Categories() {
+		byID := append([]Validation{}, info[category]...)
+		sort.Slice(byID, func(i, j int) bool {
+			return byID[i].ID < byID[j].ID
+		})
+		validations = append(validations, byID...)
+	}
+
+	return validations
+}
+
+// Failing returns true if the validation has failed (as opposed to
+// succeeded, or not having been run yet).
+func (validation *Validation) Failing() bool {
+	return validation.Status == "failure" || validation.Status == "error"
+}
+
+// FailingValidations returns the validations that have failed,
+// ordered by category and then id.
+func (info ValidationsInfo) FailingValidations() []Validation {
+	var failing []Validation
+
+	for _, validation := range info.All() {
+		if validation.Failing() {
+			failing = append(failing, validation)
+		}
+	}
+
+	return failing
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/larsks/oaitool/api"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Read the cluster spec named by the --filename option.
+func getClusterSpecFromFlags(cmd *cobra.Command) (*api.ClusterSpec, error) {
+	filename, err := cmd.Flags().GetString("filename")
+	if err != nil {
+		return nil, err
+	}
+
+	if filename == "" {
+		return nil, fmt.Errorf("you must specify a cluster spec with --filename")
+	}
+
+	return api.ClusterSpecFromFile(filename)
+}
+
+// Explain why apply and diff take -F rather than -f.
+func filenameHelp(short string) string {
+	return short + `
+
+The spec file is given with -F/--filename. (-f is the global
+--config-file option, so it can't be used here.)`
+}
+
+func NewCmdApply(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "apply -F <file>",
+		Short:         "Create or update a cluster from a cluster spec",
+		Long:          filenameHelp("Create or update a cluster from a cluster spec."),
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			if err != nil {
+				return err
+			}
+
+			if dryRun {
+				return runDiff(ctx, cmd)
+			}
+
+			spec, err := getClusterSpecFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			plan, err := ctx.api.PlanClusterCtx(cmd.Context(), spec)
+			if err != nil {
+				return err
+			}
+
+			for _, warning := range plan.Warnings {
+				log.Warn(warning)
+			}
+
+			if plan.Create != nil && plan.Create.PullSecret == "" {
+				psjson, err := getPullSecretFromFlags(ctx, cmd)
+				if err != nil {
+					return err
+				}
+				plan.Create.PullSecret = string(psjson)
+			}
+
+			cluster := plan.Cluster
+			if plan.Empty() {
+				if plan.InSync() {
+					log.Infof("cluster %s is up to date", plan.Cluster.Name)
+				}
+			} else {
+				cluster, err = ctx.api.ApplyClusterPlanCtx(cmd.Context(), plan)
+				if err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("%s %s\n", cluster.Name, cluster.ID)
+
+			// Differences we can't fix (see the warnings above) mean
+			// the cluster still doesn't match the spec.
+			if len(plan.Warnings) > 0 {
+				return ErrDrift
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("filename", "F", "", "Read cluster spec from this file (-f is --config-file)")
+	cmd.Flags().String("pull-secret", "", "Read pull secret from a file (if not set in the spec)")
+	cmd.Flags().Bool("dry-run", false, "Show what would change without changing anything")
+
+	return &cmd
+}
@@ -1,15 +1,20 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
-	"text/tabwriter"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/larsks/oaitool/api"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 )
 
 func NewCmdClusterList(ctx *Context) *cobra.Command {
@@ -20,121 +25,492 @@ func NewCmdClusterList(ctx *Context) *cobra.Command {
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			clusters, err := ctx.api.ListClusters()
+			clusters, err := ctx.api.ListClustersCtx(cmd.Context())
 			if err != nil {
 				return err
 			}
 
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-			for _, cluster := range clusters {
-				fmt.Fprintf(
-					w,
-					"%s\t%s\t%s\t%s\n",
-					cluster.Name,
-					cluster.BaseDNSDomain,
-					cluster.ID,
-					cluster.Status,
-				)
-			}
-			w.Flush()
+			return printOutput(cmd, clusters, func(w io.Writer, wide bool) error {
+				for _, cluster := range clusters {
+					fmt.Fprintf(
+						w,
+						"%s\t%s\t%s\t%s",
+						cluster.Name,
+						cluster.BaseDNSDomain,
+						cluster.ID,
+						cluster.Status,
+					)
+					if wide {
+						fmt.Fprintf(
+							w,
+							"\t%s\t%d\t%s",
+							cluster.OpenshiftVersion,
+							cluster.TotalHostCount,
+							cluster.CreatedAt.Format(time.RFC3339),
+						)
+					}
+					fmt.Fprintln(w)
+				}
 
-			return nil
+				return nil
+			})
 		},
 	}
 
+	addOutputFlag(&cmd)
+
 	return &cmd
 }
 
+// Parse --cluster-network options of the form <cidr>[=<host prefix>].
+// Networks without a host prefix use defaultPrefix (which may be 0 to
+// let the api choose).
+func getClusterNetworksFromFlags(cmd *cobra.Command, defaultPrefix int) ([]api.ClusterNetwork, error) {
+	var networks []api.ClusterNetwork
+
+	values, err := cmd.Flags().GetStringArray("cluster-network")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		network := api.ClusterNetwork{Cidr: value, HostPrefix: defaultPrefix}
+
+		if pos := strings.Index(value, "="); pos >= 0 {
+			network.Cidr = value[:pos]
+			network.HostPrefix, err = strconv.Atoi(value[pos+1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid host prefix in --cluster-network %q", value)
+			}
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// Check that a list option (e.g. --service-network) and the
+// corresponding single network option (e.g. --service-network-cidr)
+// aren't both set.
+func checkNetworkFlags(cmd *cobra.Command) error {
+	for _, network := range []string{"cluster", "service", "machine"} {
+		listFlag := fmt.Sprintf("%s-network", network)
+		cidrFlag := fmt.Sprintf("%s-network-cidr", network)
+
+		if cmd.Flags().Lookup(cidrFlag) == nil {
+			continue
+		}
+
+		if cmd.Flags().Changed(listFlag) && cmd.Flags().Changed(cidrFlag) {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", listFlag, cidrFlag)
+		}
+	}
+
+	return nil
+}
+
+func addNetworkFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("cluster-network", nil,
+		"Cluster (pod) network as <cidr>[=<host prefix>] (repeat for dual-stack)")
+	cmd.Flags().StringArray("service-network", nil, "Service network CIDR (repeat for dual-stack)")
+	cmd.Flags().StringArray("machine-network", nil, "Machine network CIDR (repeat for dual-stack)")
+}
+
 func NewCmdClusterCreate(ctx *Context) *cobra.Command {
 	cmd := cobra.Command{
-		Use:           "create <name_or_id>",
+		Use:           "create [<name>] [--from-file <file>]",
 		Short:         "Create an assisted installer cluster",
-		Args:          cobra.ExactArgs(1),
+		Args:          cobra.MaximumNArgs(1),
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var ps *api.PullSecret
+			createParams := &api.ClusterCreateParams{}
 
-			pspath, err := cmd.Flags().GetString("pull-secret")
+			fromFile, err := cmd.Flags().GetString("from-file")
 			if err != nil {
 				return err
 			}
 
-			if pspath != "" {
-				ps, err = api.PullSecretFromFile(pspath)
-			} else {
-				ps, err = ctx.api.GetPullSecret()
+			if fromFile != "" {
+				createParams, err = api.ClusterCreateParamsFromFile(fromFile)
+				if err != nil {
+					return err
+				}
 			}
 
-			if err != nil {
+			if len(args) > 0 {
+				createParams.Name = args[0]
+			}
+
+			// Options override values from --from-file. Option
+			// defaults are only used for values that aren't in the
+			// file.
+			for flag, value := range map[string]*string{
+				"openshift-version":      &createParams.OpenshiftVersion,
+				"base-domain":            &createParams.BaseDnsDomain,
+				"network-type":           &createParams.NetworkType,
+				"high-availability-mode": &createParams.HighAvailabilityMode,
+				"ocp-release-image":      &createParams.OcpReleaseImage,
+				"cluster-network-cidr":   &createParams.ClusterNetworkCidr,
+				"service-network-cidr":   &createParams.ServiceNetworkCidr,
+				"ingress-vip":            &createParams.IngressVip,
+				"http-proxy":             &createParams.HttpProxy,
+				"https-proxy":            &createParams.HttpsProxy,
+				"no-proxy":               &createParams.NoProxy,
+				"additional-ntp-source":  &createParams.AdditionalNtpSource,
+				"hyperthreading":         &createParams.Hyperthreading,
+			} {
+				if *value != "" && !cmd.Flags().Changed(flag) {
+					continue
+				}
+
+				*value, err = cmd.Flags().GetString(flag)
+				if err != nil {
+					return err
+				}
+			}
+
+			if cmd.Flags().Changed("cluster-network-host-prefix") {
+				createParams.ClusterNetworkHostPrefix, err = cmd.Flags().GetInt("cluster-network-host-prefix")
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := checkNetworkFlags(cmd); err != nil {
 				return err
 			}
 
-			psjson, err := ps.ToJSON()
-			if err != nil {
+			if cmd.Flags().Changed("cluster-network") {
+				createParams.ClusterNetworks, err = getClusterNetworksFromFlags(
+					cmd, createParams.ClusterNetworkHostPrefix)
+				if err != nil {
+					return err
+				}
+				createParams.ClusterNetworkCidr = ""
+				createParams.ClusterNetworkHostPrefix = 0
+			}
+
+			if cmd.Flags().Changed("service-network") {
+				cidrs, err := cmd.Flags().GetStringArray("service-network")
+				if err != nil {
+					return err
+				}
+				createParams.ServiceNetworks = api.ServiceNetworksFromCidrs(cidrs)
+				createParams.ServiceNetworkCidr = ""
+			}
+
+			if cmd.Flags().Changed("machine-network") {
+				cidrs, err := cmd.Flags().GetStringArray("machine-network")
+				if err != nil {
+					return err
+				}
+				createParams.MachineNetworks = api.MachineNetworksFromCidrs(cidrs)
+			}
+
+			for flag, value := range map[string]*bool{
+				"vip-dhcp-allocation":     &createParams.VipDhcpAllocation,
+				"user-managed-networking": &createParams.UserManagedNetworking,
+				"schedulable-masters":     &createParams.SchedulableMasters,
+			} {
+				if !cmd.Flags().Changed(flag) {
+					continue
+				}
+
+				*value, err = cmd.Flags().GetBool(flag)
+				if err != nil {
+					return err
+				}
+			}
+
+			if createParams.SshPublicKey == "" || cmd.Flags().Changed("ssh-public-key") {
+				createParams.SshPublicKey, err = getSshKeyFromFlags(cmd)
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := createParams.Validate(); err != nil {
 				return err
 			}
 
-			openshiftVersion, err := cmd.Flags().GetString("openshift-version")
+			if createParams.PullSecret == "" || cmd.Flags().Changed("pull-secret") {
+				psjson, err := getPullSecretFromFlags(ctx, cmd)
+				if err != nil {
+					return err
+				}
+				createParams.PullSecret = string(psjson)
+			}
+
+			log.Infof("creating cluster %s", createParams.Name)
+			log.Debugf("creating cluster with parameters: %+v", createParams)
+			cluster, err := ctx.api.CreateClusterCtx(cmd.Context(), createParams)
 			if err != nil {
 				return err
 			}
 
-			baseDnsDomain, err := cmd.Flags().GetString("base-domain")
+			fmt.Printf("%s %s\n", cluster.Name, cluster.ID)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("from-file", "", "Read cluster parameters from a JSON or YAML file")
+	cmd.Flags().String("pull-secret", "", "Read pull secret from a file")
+	cmd.Flags().String("openshift-version", "", "Set OpenShift version")
+	cmd.Flags().String("base-domain", "", "Base DNS Domain")
+	cmd.Flags().String("ssh-public-key", "", "Public ssh key")
+	cmd.Flags().String("network-type", "OpenShiftSDN", "Network type (OpenShiftSDN, OVNKubernetes)")
+	cmd.Flags().String("high-availability-mode", "", "High availability mode (Full, or None for single node)")
+	cmd.Flags().String("ocp-release-image", "", "OpenShift release image")
+	cmd.Flags().String("cluster-network-cidr", "", "Cluster (pod) network CIDR")
+	cmd.Flags().Int("cluster-network-host-prefix", 0, "Prefix length of the pod network allocated to each host")
+	cmd.Flags().String("service-network-cidr", "", "Service network CIDR")
+	cmd.Flags().String("ingress-vip", "", "Ingress virtual IP address")
+	cmd.Flags().Bool("vip-dhcp-allocation", false, "Allocate virtual IP addresses using DHCP")
+	cmd.Flags().String("http-proxy", "", "HTTP proxy url")
+	cmd.Flags().String("https-proxy", "", "HTTPS proxy url")
+	cmd.Flags().String("no-proxy", "", "Comma-separated list of destinations that bypass the proxy")
+	cmd.Flags().String("additional-ntp-source", "", "Comma-separated list of additional NTP servers")
+	cmd.Flags().String("hyperthreading", "", "Enable hyperthreading on masters, workers, all or none")
+	cmd.Flags().Bool("user-managed-networking", false, "Use user-managed networking (no VIPs)")
+	cmd.Flags().Bool("schedulable-masters", false, "Allow workloads to run on control plane nodes")
+	addNetworkFlags(&cmd)
+
+	return &cmd
+}
+
+// Parse options of the form <host>=<value>, where <host> is a host id
+// or hostname, returning the hosts and values in the order given.
+func getHostAssignmentsFromFlags(cmd *cobra.Command, flag string, cluster *api.Cluster) ([]*api.Host, []string, error) {
+	var hosts []*api.Host
+	var values []string
+
+	assignments, err := cmd.Flags().GetStringArray(flag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, assignment := range assignments {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, nil, fmt.Errorf("invalid --%s value %q: expected <host>=<value>", flag, assignment)
+		}
+
+		host, err := cluster.FindHost(parts[0])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hosts = append(hosts, host)
+		values = append(values, parts[1])
+	}
+
+	return hosts, values, nil
+}
+
+func NewCmdClusterUpdate(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "update [--name <name>] [--api-vip <ip>] [...]",
+		Short:         "Update cluster attributes",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var updateParams api.ClusterUpdateParams
+
+			cluster, err := getClusterFromFlags(ctx, cmd)
 			if err != nil {
 				return err
 			}
 
-			networkType, err := cmd.Flags().GetString("network-type")
-			if err != nil {
+			for flag, value := range map[string]**string{
+				"name":                  &updateParams.Name,
+				"base-domain":           &updateParams.BaseDnsDomain,
+				"cluster-network-cidr":  &updateParams.ClusterNetworkCidr,
+				"service-network-cidr":  &updateParams.ServiceNetworkCidr,
+				"machine-network-cidr":  &updateParams.MachineNetworkCidr,
+				"api-vip":               &updateParams.ApiVip,
+				"ingress-vip":           &updateParams.IngressVip,
+				"http-proxy":            &updateParams.HttpProxy,
+				"https-proxy":           &updateParams.HttpsProxy,
+				"no-proxy":              &updateParams.NoProxy,
+				"additional-ntp-source": &updateParams.AdditionalNtpSource,
+				"hyperthreading":        &updateParams.Hyperthreading,
+				"network-type":          &updateParams.NetworkType,
+			} {
+				if !cmd.Flags().Changed(flag) {
+					continue
+				}
+
+				flagValue, err := cmd.Flags().GetString(flag)
+				if err != nil {
+					return err
+				}
+				*value = &flagValue
+			}
+
+			if cmd.Flags().Changed("cluster-network-host-prefix") {
+				hostPrefix, err := cmd.Flags().GetInt("cluster-network-host-prefix")
+				if err != nil {
+					return err
+				}
+				updateParams.ClusterNetworkHostPrefix = &hostPrefix
+			}
+
+			if err := checkNetworkFlags(cmd); err != nil {
 				return err
 			}
 
-			if !api.ValidateNetworkType(networkType) {
-				return fmt.Errorf("invalid network type")
+			if cmd.Flags().Changed("cluster-network") {
+				defaultPrefix := 0
+				if updateParams.ClusterNetworkHostPrefix != nil {
+					defaultPrefix = *updateParams.ClusterNetworkHostPrefix
+					updateParams.ClusterNetworkHostPrefix = nil
+				}
+				updateParams.ClusterNetworks, err = getClusterNetworksFromFlags(cmd, defaultPrefix)
+				if err != nil {
+					return err
+				}
+			}
+
+			if cmd.Flags().Changed("service-network") {
+				cidrs, err := cmd.Flags().GetStringArray("service-network")
+				if err != nil {
+					return err
+				}
+				updateParams.ServiceNetworks = api.ServiceNetworksFromCidrs(cidrs)
+			}
+
+			if cmd.Flags().Changed("machine-network") {
+				cidrs, err := cmd.Flags().GetStringArray("machine-network")
+				if err != nil {
+					return err
+				}
+				updateParams.MachineNetworks = api.MachineNetworksFromCidrs(cidrs)
+			}
+
+			for flag, value := range map[string]**bool{
+				"vip-dhcp-allocation":     &updateParams.VipDhcpAllocation,
+				"user-managed-networking": &updateParams.UserManagedNetworking,
+				"schedulable-masters":     &updateParams.SchedulableMasters,
+			} {
+				if !cmd.Flags().Changed(flag) {
+					continue
+				}
+
+				flagValue, err := cmd.Flags().GetBool(flag)
+				if err != nil {
+					return err
+				}
+				*value = &flagValue
+			}
+
+			if cmd.Flags().Changed("ssh-public-key") {
+				sshKey, err := getSshKeyFromFlags(cmd)
+				if err != nil {
+					return err
+				}
+				updateParams.SshPublicKey = &sshKey
+			}
+
+			if cmd.Flags().Changed("pull-secret") {
+				psjson, err := getPullSecretFromFlags(ctx, cmd)
+				if err != nil {
+					return err
+				}
+				pullSecret := string(psjson)
+				updateParams.PullSecret = &pullSecret
 			}
 
-			sshKeyFile, err := cmd.Flags().GetString("ssh-public-key")
+			hosts, roles, err := getHostAssignmentsFromFlags(cmd, "host-role", cluster)
 			if err != nil {
 				return err
 			}
+			for i, host := range hosts {
+				updateParams.HostsRoles = append(updateParams.HostsRoles, api.HostRole{
+					ID:   host.ID,
+					Role: roles[i],
+				})
+			}
+
+			if len(updateParams.HostsRoles) > 0 {
+				if err := cluster.ValidateHostRoles(updateParams.HostsRoles); err != nil {
+					return err
+				}
+			}
 
-			var sshKey []byte
-			if sshKeyFile != "" {
-				log.Debugf("reading ssh key from %s", sshKeyFile)
-				sshKey, err = ioutil.ReadFile(sshKeyFile)
+			hosts, disks, err := getHostAssignmentsFromFlags(cmd, "installation-disk", cluster)
+			if err != nil {
+				return err
+			}
+			for i, host := range hosts {
+				inventory, err := host.GetInventory()
 				if err != nil {
 					return err
 				}
+
+				disk, err := inventory.FindDisk(disks[i])
+				if err != nil {
+					return fmt.Errorf("host %s: %w", host.RequestedHostname, err)
+				}
+
+				updateParams.DisksSelectedConfig = append(updateParams.DisksSelectedConfig, api.HostDisksConfig{
+					ID:          host.ID,
+					DisksConfig: []api.DiskConfig{{ID: disk.ID, Role: "install"}},
+				})
 			}
 
-			createParams := api.ClusterCreateParams{
-				Name:             args[0],
-				PullSecret:       string(psjson),
-				OpenshiftVersion: openshiftVersion,
-				BaseDnsDomain:    baseDnsDomain,
-				SshPublicKey:     string(sshKey),
-				NetworkType:      networkType,
+			if err := updateParams.Validate(); err != nil {
+				return err
 			}
 
-			log.Infof("creating cluster %s", createParams.Name)
-			log.Debugf("creating cluster with parameters: %+v", createParams)
-			cluster, err := ctx.api.CreateCluster(&createParams)
+			if settings := updateParams.NetworkSettings(cluster); settings != nil {
+				if err := settings.Validate(cluster.Hosts); err != nil {
+					return err
+				}
+			}
+
+			paramsJson, err := updateParams.ToJSON()
 			if err != nil {
 				return err
 			}
+			if string(paramsJson) == "{}" {
+				return fmt.Errorf("nothing to update")
+			}
 
-			fmt.Printf("%s %s\n", cluster.Name, cluster.ID)
+			log.Infof("updating cluster %s", cluster.Name)
+			if _, err := ctx.api.PatchClusterCtx(cmd.Context(), cluster.ID, &updateParams); err != nil {
+				return err
+			}
 
 			return nil
 		},
 	}
 
-	cmd.Flags().String("pull-secret", "", "Read pull secret from a file")
-	cmd.Flags().String("openshift-version", "", "Set OpenShift version")
+	cmd.Flags().String("name", "", "Rename the cluster")
 	cmd.Flags().String("base-domain", "", "Base DNS Domain")
+	cmd.Flags().String("cluster-network-cidr", "", "Cluster (pod) network CIDR")
+	cmd.Flags().Int("cluster-network-host-prefix", 0, "Prefix length of the pod network allocated to each host")
+	cmd.Flags().String("service-network-cidr", "", "Service network CIDR")
+	cmd.Flags().String("machine-network-cidr", "", "Machine network CIDR")
+	cmd.Flags().String("api-vip", "", "API virtual IP address")
+	cmd.Flags().String("ingress-vip", "", "Ingress virtual IP address")
+	cmd.Flags().Bool("vip-dhcp-allocation", false, "Allocate virtual IP addresses using DHCP")
+	cmd.Flags().String("http-proxy", "", "HTTP proxy url")
+	cmd.Flags().String("https-proxy", "", "HTTPS proxy url")
+	cmd.Flags().String("no-proxy", "", "Comma-separated list of destinations that bypass the proxy")
+	cmd.Flags().String("additional-ntp-source", "", "Comma-separated list of additional NTP servers")
 	cmd.Flags().String("ssh-public-key", "", "Public ssh key")
-	cmd.Flags().String("network-type", "OpenShiftSDN", "Network type")
+	cmd.Flags().String("pull-secret", "", "Read pull secret from a file")
+	cmd.Flags().String("hyperthreading", "", "Enable hyperthreading on masters, workers, all or none")
+	cmd.Flags().String("network-type", "", "Network type (OpenShiftSDN, OVNKubernetes)")
+	cmd.Flags().Bool("user-managed-networking", false, "Use user-managed networking (no VIPs)")
+	cmd.Flags().Bool("schedulable-masters", false, "Allow workloads to run on control plane nodes")
+	cmd.Flags().StringArray("host-role", nil, "Set host role (<host>=<role>; may be repeated)")
+	cmd.Flags().StringArray("installation-disk", nil, "Set host installation disk (<host>=<disk>; may be repeated)")
+	addNetworkFlags(&cmd)
 
 	return &cmd
 }
@@ -142,7 +518,7 @@ func NewCmdClusterCreate(ctx *Context) *cobra.Command {
 func NewCmdClusterSetVips(ctx *Context) *cobra.Command {
 	cmd := cobra.Command{
 		Use:           "set-vips --api-vip a.b.c.d --ingress-vip w.x.y.z",
-		Short:         "Create an assisted installer cluster",
+		Short:         "Set cluster API and ingress VIPs",
 		Args:          cobra.NoArgs,
 		SilenceErrors: true,
 		SilenceUsage:  true,
@@ -152,7 +528,7 @@ func NewCmdClusterSetVips(ctx *Context) *cobra.Command {
 				return err
 			}
 
-			if cluster.MachineNetworkCidr == "" {
+			if len(cluster.MachineNetworkCidrs()) == 0 {
 				return fmt.Errorf("cluster does not have a machine network defined")
 			}
 
@@ -166,13 +542,19 @@ func NewCmdClusterSetVips(ctx *Context) *cobra.Command {
 				return err
 			}
 
+			settings := cluster.NetworkSettings()
+			settings.ApiVip, settings.IngressVip = apiVip, ingressVip
+			if err := settings.Validate(cluster.Hosts); err != nil {
+				return err
+			}
+
 			networkPatch := api.ClusterNetworkPatch{
 				ApiVip:            apiVip,
 				IngressVip:        ingressVip,
 				VipDhcpAllocation: false,
 			}
 			log.Debugf("patching cluster network configuration: %+v", networkPatch)
-			_, err = ctx.api.PatchCluster(cluster.ID, &networkPatch)
+			_, err = ctx.api.PatchClusterCtx(cmd.Context(), cluster.ID, &networkPatch)
 			if err != nil {
 				return err
 			}
@@ -215,15 +597,15 @@ func NewCmdClusterInstall(ctx *Context) *cobra.Command {
 				switch {
 				case mode == "start" && flagval:
 					log.Infof("starting install of cluster %s (%s)", cluster.Name, cluster.ID)
-					err = ctx.api.InstallCluster(cluster.ID)
+					err = ctx.api.InstallClusterCtx(cmd.Context(), cluster.ID)
 					action = true
 				case mode == "cancel" && flagval:
 					log.Infof("starting install of cluster %s (%s)", cluster.Name, cluster.ID)
-					err = ctx.api.CancelCluster(cluster.ID)
+					err = ctx.api.CancelClusterCtx(cmd.Context(), cluster.ID)
 					action = true
 				case mode == "reset" && flagval:
 					log.Infof("starting install of cluster %s (%s)", cluster.Name, cluster.ID)
-					err = ctx.api.ResetCluster(cluster.ID)
+					err = ctx.api.ResetClusterCtx(cmd.Context(), cluster.ID)
 					action = true
 				}
 
@@ -261,7 +643,7 @@ func NewCmdClusterDelete(ctx *Context) *cobra.Command {
 			}
 
 			log.Infof("deleting cluster %s", cluster.Name)
-			if err := ctx.api.DeleteCluster(cluster.ID); err != nil {
+			if err := ctx.api.DeleteClusterCtx(cmd.Context(), cluster.ID); err != nil {
 				return err
 			}
 
@@ -297,9 +679,10 @@ func NewCmdClusterShow(ctx *Context) *cobra.Command {
 				}
 
 				os.Stdout.Write(clusterJson)
-			} else {
+				return nil
+			}
 
-				w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+			return printOutput(cmd, cluster, func(w io.Writer, wide bool) error {
 				fmt.Fprintf(w, "Name\t%s\n", cluster.Name)
 				fmt.Fprintf(w, "BaseDNSDomain\t%s\n", cluster.BaseDNSDomain)
 				fmt.Fprintf(w, "ID\t%s\n", cluster.ID)
@@ -308,14 +691,94 @@ func NewCmdClusterShow(ctx *Context) *cobra.Command {
 				fmt.Fprintf(w, "IngressVip\t%s\n", cluster.IngressVip)
 				fmt.Fprintf(w, "OpenshiftVersion\t%s\n", cluster.OpenshiftVersion)
 				fmt.Fprintf(w, "Status\t%s\n", cluster.Status)
-				w.Flush()
+				if wide {
+					fmt.Fprintf(w, "StatusInfo\t%s\n", cluster.StatusInfo)
+					fmt.Fprintf(w, "HighAvailabilityMode\t%s\n", cluster.HighAvailabilityMode)
+					fmt.Fprintf(w, "NetworkType\t%s\n", cluster.NetworkType)
+					fmt.Fprintf(w, "ClusterNetworks\t%s\n", strings.Join(cluster.ClusterNetworkCidrs(), " "))
+					fmt.Fprintf(w, "ServiceNetworks\t%s\n", strings.Join(cluster.ServiceNetworkCidrs(), " "))
+					fmt.Fprintf(w, "MachineNetworks\t%s\n", strings.Join(cluster.MachineNetworkCidrs(), " "))
+					fmt.Fprintf(w, "TotalHostCount\t%d\n", cluster.TotalHostCount)
+					fmt.Fprintf(w, "CreatedAt\t%s\n", cluster.CreatedAt.Format(time.RFC3339))
+				}
+
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().BoolP("json", "j", false, "Show full JSON data (compact form of --output json)")
+	addOutputFlag(&cmd)
+
+	return &cmd
+}
+
+func NewCmdClusterExport(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "export",
+		Short:         "Export a cluster as a spec for use with apply",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cluster, err := getClusterFromFlags(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			spec := api.ClusterSpecFromCluster(cluster)
+
+			name, err := cmd.Flags().GetString("name")
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				spec.Name = name
 			}
 
+			specYaml, err := yaml.Marshal(spec)
+			if err != nil {
+				return err
+			}
+
+			os.Stdout.Write(specYaml)
 			return nil
 		},
 	}
 
-	cmd.Flags().BoolP("json", "j", false, "Show full JSON data")
+	cmd.Flags().String("name", "", "Use this name in the exported spec")
+
+	return &cmd
+}
+
+func NewCmdClusterValidations(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "validations [--failing] [--category <category>]",
+		Short:         "Show cluster validation results",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cluster, err := getClusterFromFlags(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			info, err := cluster.GetValidationsInfo()
+			if err != nil {
+				return err
+			}
+
+			validations, err := getValidationsFromFlags(cmd, info)
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd, validations, validationsTable(validations))
+		},
+	}
+
+	addValidationsFlags(&cmd)
 
 	return &cmd
 }
@@ -333,11 +796,25 @@ func NewCmdClusterStatus(ctx *Context) *cobra.Command {
 				return err
 			}
 
-			fmt.Println(cluster.Status)
-			return nil
+			status := struct {
+				Status     string `json:"status"`
+				StatusInfo string `json:"status_info"`
+			}{cluster.Status, cluster.StatusInfo}
+
+			return printOutput(cmd, status, func(w io.Writer, wide bool) error {
+				if wide {
+					fmt.Fprintf(w, "%s\t%s\n", status.Status, status.StatusInfo)
+				} else {
+					fmt.Fprintln(w, status.Status)
+				}
+
+				return nil
+			})
 		},
 	}
 
+	addOutputFlag(&cmd)
+
 	return &cmd
 }
 
@@ -354,6 +831,42 @@ func NewCmdClusterGetImageUrl(ctx *Context) *cobra.Command {
 				return err
 			}
 
+			infraEnvName, err := cmd.Flags().GetString("infra-env")
+			if err != nil {
+				return err
+			}
+
+			useInfraEnv, err := cmd.Flags().GetBool("use-infra-env")
+			if err != nil {
+				return err
+			}
+
+			// Use the cluster's infra-env if its hosts booted from
+			// one, or (with --use-infra-env) if the api knows of one
+			// bound to the cluster.
+			if infraEnvName == "" && (useInfraEnv || cluster.ImageInfo.DownloadUrl == "") {
+				infraEnvName = getClusterInfraEnvID(ctx, cmd, cluster, useInfraEnv)
+				if infraEnvName == "" && useInfraEnv {
+					return fmt.Errorf("no infra-env found for cluster %s", cluster.Name)
+				}
+			}
+
+			if infraEnvName != "" {
+				infraEnv, err := ctx.api.FindInfraEnvCtx(cmd.Context(), infraEnvName)
+				if err != nil {
+					return err
+				}
+
+				log.Infof("using discovery image from infra-env %s", infraEnv.ID)
+				imageUrl, err := getInfraEnvImageUrl(ctx, cmd, infraEnv)
+				if err != nil {
+					return err
+				}
+
+				fmt.Println(imageUrl)
+				return nil
+			}
+
 			if cluster.ImageInfo.DownloadUrl == "" {
 				imageType, err := cmd.Flags().GetString("image-type")
 				if err != nil {
@@ -364,7 +877,7 @@ func NewCmdClusterGetImageUrl(ctx *Context) *cobra.Command {
 				}
 
 				log.Info("generating discovery image")
-				cluster, err = ctx.api.CreateDiscoveryImage(cluster.ID, imageType, "")
+				cluster, err = ctx.api.CreateDiscoveryImageCtx(cmd.Context(), cluster.ID, imageType, "")
 				if err != nil {
 					return err
 				}
@@ -374,6 +887,7 @@ func NewCmdClusterGetImageUrl(ctx *Context) *cobra.Command {
 				}
 			}
 
+			log.Infof("using discovery image from cluster %s", cluster.ID)
 			log.Debugf("image info: %+v", cluster.ImageInfo)
 			fmt.Println(cluster.ImageInfo.DownloadUrl)
 			return nil
@@ -381,6 +895,8 @@ func NewCmdClusterGetImageUrl(ctx *Context) *cobra.Command {
 	}
 
 	cmd.Flags().String("image-type", "minimal-iso", "set discovery image type")
+	cmd.Flags().String("infra-env", "", "get image url from this infra-env")
+	cmd.Flags().Bool("use-infra-env", false, "get image url from the infra-env bound to the cluster")
 
 	return &cmd
 }
@@ -398,7 +914,7 @@ func NewCmdClusterGetKubeconfig(ctx *Context) *cobra.Command {
 				return err
 			}
 
-			kubeconfig, err := ctx.api.GetKubeconfig(cluster.ID)
+			kubeconfig, err := ctx.api.GetKubeconfigCtx(cmd.Context(), cluster.ID)
 			if err != nil {
 				return err
 			}
@@ -429,7 +945,7 @@ func NewCmdClusterGetFile(ctx *Context) *cobra.Command {
 				return fmt.Errorf("invalid filename")
 			}
 
-			content, err := ctx.api.GetFile(cluster.ID, args[0])
+			content, err := ctx.api.GetFileCtx(cmd.Context(), cluster.ID, args[0])
 			if err != nil {
 				return err
 			}
@@ -443,6 +959,240 @@ func NewCmdClusterGetFile(ctx *Context) *cobra.Command {
 	return &cmd
 }
 
+// Return the events that are at least as severe as minSeverity and
+// that we haven't seen before, recording them in seen.
+func filterEvents(events api.EventList, minSeverity string, seen map[string]bool) api.EventList {
+	var selected api.EventList
+
+	for _, event := range events {
+		key := fmt.Sprintf("%s|%s|%s|%s",
+			event.EventTime.Format(time.RFC3339Nano), event.HostID, event.Name, event.Message)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if minSeverity != "" && api.SeverityLevel(event.Severity) < api.SeverityLevel(minSeverity) {
+			continue
+		}
+
+		selected = append(selected, event)
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].EventTime.Before(selected[j].EventTime)
+	})
+
+	return selected
+}
+
+func eventTable(cluster *api.Cluster, events api.EventList) tableFunc {
+	hostNames := map[string]string{}
+	for i := range cluster.Hosts {
+		hostNames[cluster.Hosts[i].ID] = hostDisplayName(&cluster.Hosts[i])
+	}
+
+	return func(w io.Writer, wide bool) error {
+		for _, event := range events {
+			subject := "cluster"
+			if event.HostID != "" {
+				subject = hostNames[event.HostID]
+				if subject == "" {
+					subject = event.HostID
+				}
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s",
+				event.EventTime.Local().Format(time.RFC3339), event.Severity, subject, event.Message)
+			if wide {
+				fmt.Fprintf(w, "\t%s\t%s", event.Name, event.Category)
+			}
+			fmt.Fprintln(w)
+		}
+
+		return nil
+	}
+}
+
+func NewCmdClusterEvents(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "events [--host <name_or_id>] [--severity <severity>] [--follow [--interval <seconds>]]",
+		Short:         "Show cluster events",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var hostid string
+
+			cluster, err := getClusterFromFlags(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			hostname, err := cmd.Flags().GetString("host")
+			if err != nil {
+				return err
+			}
+
+			if hostname != "" {
+				host, err := cluster.FindHost(hostname)
+				if err != nil {
+					return err
+				}
+				hostid = host.ID
+			}
+
+			severity, err := cmd.Flags().GetString("severity")
+			if err != nil {
+				return err
+			}
+			if severity != "" && !api.ValidateEventSeverity(severity) {
+				return fmt.Errorf("invalid severity: %s", severity)
+			}
+
+			follow, err := cmd.Flags().GetBool("follow")
+			if err != nil {
+				return err
+			}
+
+			interval, err := cmd.Flags().GetInt("interval")
+			if err != nil {
+				return err
+			}
+
+			seen := map[string]bool{}
+			var since time.Time
+
+			for {
+				events, err := ctx.api.ListEventsCtx(cmd.Context(), cluster.ID, hostid, since)
+				if err != nil {
+					return err
+				}
+
+				// We ask for events at or after the most recent
+				// event we've seen, so that we don't miss events
+				// with the same timestamp; seen takes care of the
+				// duplicates.
+				for _, event := range events {
+					if event.EventTime.After(since) {
+						since = event.EventTime
+					}
+				}
+
+				events = filterEvents(events, severity, seen)
+				if !follow || len(events) > 0 {
+					if err := printOutput(cmd, events, eventTable(cluster, events)); err != nil {
+						return err
+					}
+				}
+
+				if !follow {
+					return nil
+				}
+
+				if err := sleepCtx(cmd.Context(), time.Duration(interval)*time.Second); err != nil {
+					if errors.Is(err, context.Canceled) {
+						return nil
+					}
+					return err
+				}
+			}
+		},
+	}
+
+	cmd.Flags().String("host", "", "only show events for this host")
+	cmd.Flags().String("severity", "", "only show events at least this severe (info, warning, error, critical)")
+	cmd.Flags().Bool("follow", false, "wait for and print new events")
+	cmd.Flags().Int("interval", 10, "how often to check for new events when following (seconds)")
+	addOutputFlag(&cmd)
+
+	return &cmd
+}
+
+func NewCmdClusterLogs(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "logs [--type <all|controller|host>] [--host <name_or_id> ...] [--dest <dir>] [--extract]",
+		Short:         "Download cluster logs",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cluster, err := getClusterFromFlags(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			logsType, err := cmd.Flags().GetString("type")
+			if err != nil {
+				return err
+			}
+			if !api.ValidateLogsType(logsType) {
+				return fmt.Errorf("invalid logs type: %s", logsType)
+			}
+
+			hostnames, err := cmd.Flags().GetStringArray("host")
+			if err != nil {
+				return err
+			}
+
+			dest, err := cmd.Flags().GetString("dest")
+			if err != nil {
+				return err
+			}
+
+			extract, err := cmd.Flags().GetBool("extract")
+			if err != nil {
+				return err
+			}
+
+			// Logs for selected hosts, or for every host.
+			if len(hostnames) > 0 || logsType == "host" {
+				var hosts []*api.Host
+
+				for _, hostname := range hostnames {
+					host, err := cluster.FindHost(hostname)
+					if err != nil {
+						return err
+					}
+					hosts = append(hosts, host)
+				}
+
+				if len(hostnames) == 0 {
+					for i := range cluster.Hosts {
+						if cluster.Hosts[i].LogsCollectedAt.IsZero() {
+							log.Warnf("no logs have been collected for host %s", hostDisplayName(&cluster.Hosts[i]))
+							continue
+						}
+						hosts = append(hosts, &cluster.Hosts[i])
+					}
+				}
+
+				for _, host := range hosts {
+					if err := downloadHostLogs(ctx, cmd, cluster, host, dest, extract); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			}
+
+			if logsType == "controller" && cluster.ControllerLogsCollectedAt.IsZero() {
+				return fmt.Errorf("no controller logs have been collected for cluster %s", cluster.Name)
+			}
+
+			name := fmt.Sprintf("%s_%s_logs", cluster.Name, logsType)
+			_, err = downloadLogs(ctx, cmd, cluster.ID, logsType, "", dest, name, extract)
+			return err
+		},
+	}
+
+	cmd.Flags().String("type", "all", "which logs to download (all, controller or host)")
+	cmd.Flags().StringArray("host", nil, "download logs for this host (may be repeated)")
+	addLogsFlags(&cmd)
+
+	return &cmd
+}
+
 func NewCmdClusterWaitForStatus(ctx *Context) *cobra.Command {
 	cmd := cobra.Command{
 		Use:           "wait-for-status [--interval <seconds>] [--retries <retries>] [--timeout <seconds>] <status>",
@@ -479,8 +1229,10 @@ func NewCmdClusterWaitForStatus(ctx *Context) *cobra.Command {
 			log.Infof("waiting for cluster %s to reach status %s",
 				cluster.Name, desired_status)
 
+			waitctx, cancel := withTimeout(cmd.Context(), timeout)
+			defer cancel()
+
 			retry_count := 0
-			time_start := time.Now()
 			for {
 				if cluster.Status == desired_status {
 					break
@@ -489,19 +1241,17 @@ func NewCmdClusterWaitForStatus(ctx *Context) *cobra.Command {
 				log.Debugf("checking status, have %s want %s",
 					cluster.Status, desired_status)
 
-				if timeout > 0 && time.Since(time_start).Seconds() > float64(timeout) {
-					return fmt.Errorf("timed out waiting for status")
-				}
-
 				retry_count++
 				if retries > 0 && retry_count > retries {
 					return fmt.Errorf("too many retries waiting for status")
 				}
 
-				time.Sleep(time.Duration(interval) * time.Second)
-				cluster, err = ctx.api.GetCluster(cluster.ID)
+				if err := sleepCtx(waitctx, time.Duration(interval)*time.Second); err != nil {
+					return waitError(err)
+				}
+				cluster, err = ctx.api.GetClusterCtx(waitctx, cluster.ID)
 				if err != nil {
-					return err
+					return waitError(err)
 				}
 
 			}
@@ -517,6 +1267,54 @@ func NewCmdClusterWaitForStatus(ctx *Context) *cobra.Command {
 	return &cmd
 }
 
+func NewCmdClusterUse(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "use <name_or_id>",
+		Short:         "Set the default cluster for the current context",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cluster, err := ctx.api.FindClusterCtx(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			cfgFile, err := configFilePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := readConfigFile(cfgFile)
+			if err != nil {
+				return err
+			}
+
+			if name := selectedContext(config); name != "" {
+				cfgctx, ok := config.Contexts[name]
+				if !ok {
+					return fmt.Errorf("no context named %s", name)
+				}
+
+				log.Infof("setting default cluster for context %s to %s (%s)",
+					name, cluster.Name, cluster.ID)
+				cfgctx.Cluster = cluster.ID
+				config.Contexts[name] = cfgctx
+			} else {
+				log.Infof("setting default cluster to %s (%s)", cluster.Name, cluster.ID)
+				if config.Settings == nil {
+					config.Settings = map[string]interface{}{}
+				}
+				config.Settings["cluster"] = cluster.ID
+			}
+
+			return writeConfigFile(cfgFile, config)
+		},
+	}
+
+	return &cmd
+}
+
 func NewCmdCluster(ctx *Context) *cobra.Command {
 	cmd := cobra.Command{
 		Use:   "cluster",
@@ -527,11 +1325,18 @@ func NewCmdCluster(ctx *Context) *cobra.Command {
 
 	cmd.AddCommand(
 		NewCmdClusterList(ctx),
+		NewCmdClusterUse(ctx),
 		NewCmdClusterShow(ctx),
+		NewCmdClusterExport(ctx),
 		NewCmdClusterStatus(ctx),
+		NewCmdClusterValidations(ctx),
+		NewCmdClusterWhy(ctx),
+		NewCmdClusterEvents(ctx),
+		NewCmdClusterLogs(ctx),
 		NewCmdClusterDelete(ctx),
 		NewCmdClusterInstall(ctx),
 		NewCmdClusterCreate(ctx),
+		NewCmdClusterUpdate(ctx),
 		NewCmdClusterSetVips(ctx),
 		NewCmdClusterGetImageUrl(ctx),
 		NewCmdClusterGetKubeconfig(ctx),
@@ -0,0 +1,345 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/larsks/oaitool/api"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// ConfigContext is a named set of connection settings. The keys
+	// are the same as the corresponding global options.
+	ConfigContext struct {
+		ApiUrl       string `yaml:"api-url,omitempty" json:"api-url,omitempty"`
+		AuthMode     string `yaml:"auth-mode,omitempty" json:"auth-mode,omitempty"`
+		OfflineToken string `yaml:"offline-token,omitempty" json:"offline-token,omitempty"`
+		Token        string `yaml:"token,omitempty" json:"token,omitempty"`
+		TokenUrl     string `yaml:"token-url,omitempty" json:"token-url,omitempty"`
+		ClientID     string `yaml:"client-id,omitempty" json:"client-id,omitempty"`
+		Cluster      string `yaml:"cluster,omitempty" json:"cluster,omitempty"`
+		CABundle     string `yaml:"ca-bundle,omitempty" json:"ca-bundle,omitempty"`
+	}
+
+	// ConfigFile is the on-disk configuration. Keys other than the
+	// context settings are preserved in Settings when the file is
+	// rewritten.
+	ConfigFile struct {
+		CurrentContext string                   `yaml:"current-context,omitempty" json:"current-context,omitempty"`
+		Contexts       map[string]ConfigContext `yaml:"contexts,omitempty" json:"contexts,omitempty"`
+		Settings       map[string]interface{}   `yaml:",inline"`
+	}
+)
+
+// Return a copy of the context with credentials replaced by a
+// placeholder, for display.
+func (cfgctx ConfigContext) redacted() ConfigContext {
+	for _, value := range []*string{&cfgctx.OfflineToken, &cfgctx.Token} {
+		if *value != "" {
+			*value = "<redacted>"
+		}
+	}
+
+	return cfgctx
+}
+
+// Return the settings in a context as a map suitable for merging into
+// the viper configuration.
+func (cfgctx *ConfigContext) settings() map[string]interface{} {
+	settings := map[string]interface{}{}
+
+	for key, value := range map[string]string{
+		"api-url":       cfgctx.ApiUrl,
+		"auth-mode":     cfgctx.AuthMode,
+		"offline-token": cfgctx.OfflineToken,
+		"token":         cfgctx.Token,
+		"token-url":     cfgctx.TokenUrl,
+		"client-id":     cfgctx.ClientID,
+		"cluster":       cfgctx.Cluster,
+		"ca-bundle":     cfgctx.CABundle,
+	} {
+		if value != "" {
+			settings[key] = value
+		}
+	}
+
+	return settings
+}
+
+// Return the path to which we write configuration changes: the config
+// file we read at startup, or the default location if there wasn't
+// one.
+func configFilePath() (string, error) {
+	if cfgFile := viper.ConfigFileUsed(); cfgFile != "" {
+		return cfgFile, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(home, ".config", "oaitool", "config.yml"), nil
+}
+
+// Read the config file at path. A missing file is treated as an
+// empty configuration.
+func readConfigFile(path string) (*ConfigFile, error) {
+	var config ConfigFile
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if config.Contexts == nil {
+		config.Contexts = map[string]ConfigContext{}
+	}
+
+	return &config, nil
+}
+
+func writeConfigFile(filepath string, config *ConfigFile) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(filepath), 0700); err != nil {
+		return err
+	}
+
+	log.Debugf("writing config to %s", filepath)
+	return ioutil.WriteFile(filepath, data, 0600)
+}
+
+// Return the name of the context selected by the --context option or,
+// if that isn't set, the current-context key in the config file.
+func selectedContext(config *ConfigFile) string {
+	if name := viper.GetString("context"); name != "" {
+		return name
+	}
+
+	return config.CurrentContext
+}
+
+// Apply the settings from the selected context (from the --context
+// option or the current-context key in the config file) on top of
+// the top-level settings in the config file. Options and environment
+// variables still take precedence.
+func initConfigContext() error {
+	cfgFile := viper.ConfigFileUsed()
+	if cfgFile == "" {
+		return nil
+	}
+
+	config, err := readConfigFile(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	name := selectedContext(config)
+	if name == "" {
+		return nil
+	}
+
+	cfgctx, ok := config.Contexts[name]
+	if !ok {
+		return fmt.Errorf("no context named %s", name)
+	}
+
+	log.Debugf("using context %s", name)
+	return viper.MergeConfigMap(cfgctx.settings())
+}
+
+func NewCmdConfigGetContexts(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "get-contexts",
+		Short:         "List contexts defined in the config file",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgFile, err := configFilePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := readConfigFile(cfgFile)
+			if err != nil {
+				return err
+			}
+
+			current := selectedContext(config)
+
+			var names []string
+			for name := range config.Contexts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			type namedContext struct {
+				Name    string `json:"name"`
+				Current bool   `json:"current"`
+				ConfigContext
+			}
+
+			var contexts []namedContext
+			for _, name := range names {
+				contexts = append(contexts, namedContext{
+					Name:          name,
+					Current:       name == current,
+					ConfigContext: config.Contexts[name].redacted(),
+				})
+			}
+
+			return printOutput(cmd, contexts, func(w io.Writer, wide bool) error {
+				fmt.Fprintf(w, "CURRENT\tNAME\tAPI-URL\tAUTH-MODE\tCLUSTER\n")
+				for _, cfgctx := range contexts {
+					marker := ""
+					if cfgctx.Current {
+						marker = "*"
+					}
+
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+						marker, cfgctx.Name, cfgctx.ApiUrl, cfgctx.AuthMode, cfgctx.Cluster)
+				}
+
+				return nil
+			})
+		},
+	}
+
+	addOutputFlag(&cmd)
+
+	return &cmd
+}
+
+func NewCmdConfigUseContext(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "use-context <name>",
+		Short:         "Set the current context",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgFile, err := configFilePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := readConfigFile(cfgFile)
+			if err != nil {
+				return err
+			}
+
+			if _, ok := config.Contexts[args[0]]; !ok {
+				return fmt.Errorf("no context named %s", args[0])
+			}
+
+			log.Infof("switching to context %s", args[0])
+			config.CurrentContext = args[0]
+			return writeConfigFile(cfgFile, config)
+		},
+	}
+
+	return &cmd
+}
+
+func NewCmdConfigSetContext(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "set-context <name> [--api-url <url>] [--auth-mode <mode>] [...]",
+		Short:         "Create or update a context",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgFile, err := configFilePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := readConfigFile(cfgFile)
+			if err != nil {
+				return err
+			}
+
+			cfgctx := config.Contexts[args[0]]
+			for flag, value := range map[string]*string{
+				"api-url":       &cfgctx.ApiUrl,
+				"auth-mode":     &cfgctx.AuthMode,
+				"offline-token": &cfgctx.OfflineToken,
+				"token":         &cfgctx.Token,
+				"token-url":     &cfgctx.TokenUrl,
+				"client-id":     &cfgctx.ClientID,
+				"cluster":       &cfgctx.Cluster,
+				"ca-bundle":     &cfgctx.CABundle,
+			} {
+				if !cmd.Flags().Changed(flag) {
+					continue
+				}
+
+				*value, err = cmd.Flags().GetString(flag)
+				if err != nil {
+					return err
+				}
+			}
+
+			if cfgctx.AuthMode != "" && !api.ValidateAuthMode(cfgctx.AuthMode) {
+				return fmt.Errorf("invalid auth mode: %s", cfgctx.AuthMode)
+			}
+
+			log.Infof("updating context %s", args[0])
+			config.Contexts[args[0]] = cfgctx
+			return writeConfigFile(cfgFile, config)
+		},
+	}
+
+	cmd.Flags().String("api-url", "", "api endpoint")
+	cmd.Flags().String("auth-mode", "", "authentication mode (rhsso, none, static-bearer, custom-oidc)")
+	cmd.Flags().String("offline-token", "", "offline api token")
+	cmd.Flags().String("token", "", "bearer token for static-bearer auth mode")
+	cmd.Flags().String("token-url", "", "token endpoint for custom-oidc auth mode")
+	cmd.Flags().String("client-id", "", "client id for custom-oidc auth mode")
+	cmd.Flags().String("cluster", "", "default cluster id or name")
+	cmd.Flags().String("ca-bundle", "", "path to a file of trusted CA certificates")
+
+	return &cmd
+}
+
+func NewCmdConfig(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "config",
+		Short: "Commands for managing the oaitool configuration",
+
+		// These commands don't talk to the api, so we don't need
+		// to initialize an api client.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := initLogging(cmd); err != nil {
+				return err
+			}
+
+			return initConfig(cmd)
+		},
+	}
+
+	cmd.AddCommand(
+		NewCmdConfigGetContexts(ctx),
+		NewCmdConfigUseContext(ctx),
+		NewCmdConfigSetContext(ctx),
+	)
+
+	return &cmd
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Ask the user a yes/no question on the terminal. Anything other than
+// "y" or "yes" (including end of input) is a no.
+func confirm(cmd *cobra.Command, prompt string) (bool, error) {
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s [y/N] ", prompt)
+
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(cmd.ErrOrStderr())
+		return false, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// Add the --yes and --dry-run options to commands that show a preview
+// of their changes and ask before making them.
+func addConfirmFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+	cmd.Flags().Bool("dry-run", false, "Show what would change without changing anything")
+}
+
+// Decide whether to go ahead with the changes in a preview, based on
+// the --yes and --dry-run options and, failing those, by asking.
+func confirmFromFlags(cmd *cobra.Command) (bool, error) {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return false, err
+	}
+	if dryRun {
+		return false, nil
+	}
+
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return false, err
+	}
+	if yes {
+		return true, nil
+	}
+
+	return confirm(cmd, "Apply these changes?")
+}
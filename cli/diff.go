@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/larsks/oaitool/api"
+	"github.com/spf13/cobra"
+)
+
+// ErrDrift is returned by diff and apply --dry-run when the cluster
+// doesn't match the spec, and by apply when there are differences it
+// can't fix. main exits with status 2 (and no error message) when it
+// sees this error.
+var ErrDrift = errors.New("cluster does not match spec")
+
+// Return a name for a host suitable for showing in a diff.
+func planHostName(cluster *api.Cluster, hostid string) string {
+	for _, host := range cluster.Hosts {
+		if host.ID == hostid && host.RequestedHostname != "" {
+			return fmt.Sprintf("%s (%s)", host.RequestedHostname, host.ID)
+		}
+	}
+
+	return hostid
+}
+
+func formatPlanValue(value interface{}) string {
+	if value == nil {
+		return "<unset>"
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(data)
+}
+
+func writePlanRequest(w io.Writer, method, path string, body interface{}) error {
+	data, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n%s %s\n%s\n", method, path, data)
+	return nil
+}
+
+// Write a readable description of a plan: the attributes that would
+// change, followed by the request bodies that would be sent.
+func writePlan(w io.Writer, plan *api.ClusterPlan) error {
+	for _, warning := range plan.Warnings {
+		fmt.Fprintf(w, "! %s\n", warning)
+	}
+
+	if plan.Create != nil {
+		params := *plan.Create
+		if params.PullSecret != "" {
+			params.PullSecret = "<redacted>"
+		}
+
+		fmt.Fprintf(w, "+ create cluster %s\n", params.Name)
+		fmt.Fprintln(w, "  (hosts, manifests and other attributes will be planned once the cluster exists)")
+		return writePlanRequest(w, "POST", "/clusters", params)
+	}
+
+	if plan.InSync() {
+		fmt.Fprintf(w, "cluster %s (%s) is up to date\n", plan.Cluster.Name, plan.Cluster.ID)
+		return nil
+	}
+
+	fmt.Fprintf(w, "cluster %s (%s)\n", plan.Cluster.Name, plan.Cluster.ID)
+
+	generic, err := toGeneric(plan.Cluster)
+	if err != nil {
+		return err
+	}
+	current := generic.(map[string]interface{})
+
+	for _, key := range sortedKeys(plan.Patch) {
+		switch key {
+		case "hosts_roles":
+			for _, role := range plan.Patch[key].([]api.HostRole) {
+				fmt.Fprintf(w, "~ host %s role -> %s\n",
+					planHostName(plan.Cluster, role.ID), role.Role)
+			}
+		case "disks_selected_config":
+			for _, disks := range plan.Patch[key].([]api.HostDisksConfig) {
+				for _, disk := range disks.DisksConfig {
+					fmt.Fprintf(w, "~ host %s installation disk -> %s\n",
+						planHostName(plan.Cluster, disks.ID), disk.ID)
+				}
+			}
+		default:
+			fmt.Fprintf(w, "~ %s: %s -> %s\n", key,
+				formatPlanValue(current[key]), formatPlanValue(plan.Patch[key]))
+		}
+	}
+
+	for _, hostname := range plan.Hostnames {
+		fmt.Fprintf(w, "~ host %s hostname -> %s\n",
+			planHostName(plan.Cluster, hostname.ID), hostname.HostName)
+	}
+
+	for _, manifest := range plan.Manifests {
+		marker := "+"
+		if manifest.Replace {
+			marker = "~"
+		}
+		fmt.Fprintf(w, "%s manifest %s/%s\n", marker, manifest.Folder, manifest.FileName)
+	}
+
+	path := fmt.Sprintf("/clusters/%s", plan.Cluster.ID)
+
+	if len(plan.Patch) > 0 {
+		if err := writePlanRequest(w, "PATCH", path, plan.Patch); err != nil {
+			return err
+		}
+	}
+
+	if len(plan.Hostnames) > 0 {
+		if err := writePlanRequest(w, "PATCH", path, api.HostNameList{HostNames: plan.Hostnames}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Plan the changes needed to make the cluster match the spec named by
+// --filename, print them, and return ErrDrift if there are any (or if
+// there are differences that apply can't fix).
+func runDiff(ctx *Context, cmd *cobra.Command) error {
+	spec, err := getClusterSpecFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	plan, err := ctx.api.PlanClusterCtx(cmd.Context(), spec)
+	if err != nil {
+		return err
+	}
+
+	if err := writePlan(cmd.OutOrStdout(), plan); err != nil {
+		return err
+	}
+
+	if !plan.InSync() {
+		return ErrDrift
+	}
+
+	return nil
+}
+
+func NewCmdDiff(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "diff -F <file>",
+		Short:         "Show the changes apply would make to a cluster",
+		Long:          filenameHelp("Show the changes apply would make to a cluster."),
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDiff(ctx, cmd)
+		},
+	}
+
+	cmd.Flags().StringP("filename", "F", "", "Read cluster spec from this file (-f is --config-file)")
+
+	return &cmd
+}
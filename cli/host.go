@@ -2,9 +2,8 @@ package cli
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"strings"
-	"text/tabwriter"
 	"time"
 
 	"github.com/larsks/oaitool/api"
@@ -12,96 +11,263 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// Return the name a host was given, or its id if it hasn't been
+// given one.
+func hostDisplayName(host *api.Host) string {
+	if host.RequestedHostname != "" {
+		return host.RequestedHostname
+	}
+
+	return host.ID
+}
+
+// Return the hosts in the infra-env named by the --infra-env option
+// or, if that isn't set, the hosts in the cluster named by the
+// --cluster option.
+func getHostsFromFlags(ctx *Context, cmd *cobra.Command) ([]api.Host, error) {
+	infraEnvName, err := cmd.Flags().GetString("infra-env")
+	if err != nil {
+		return nil, err
+	}
+
+	if infraEnvName != "" {
+		infraEnv, err := ctx.api.FindInfraEnvCtx(cmd.Context(), infraEnvName)
+		if err != nil {
+			return nil, err
+		}
+		log.Debugf("found infra-env %s", infraEnv.ID)
+
+		return ctx.api.ListInfraEnvHostsCtx(cmd.Context(), infraEnv.ID)
+	}
+
+	cluster, err := getClusterFromFlags(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("found cluster %s", cluster.ID)
+
+	return cluster.Hosts, nil
+}
+
+// Find a host by id or name in the infra-env named by the --infra-env
+// option or the cluster named by the --cluster option.
+func findHostFromFlags(ctx *Context, cmd *cobra.Command, name string) (*api.Host, error) {
+	infraEnvName, err := cmd.Flags().GetString("infra-env")
+	if err != nil {
+		return nil, err
+	}
+
+	if infraEnvName != "" {
+		infraEnv, err := ctx.api.FindInfraEnvCtx(cmd.Context(), infraEnvName)
+		if err != nil {
+			return nil, err
+		}
+		log.Debugf("found infra-env %s", infraEnv.ID)
+
+		return ctx.api.FindInfraEnvHostCtx(cmd.Context(), infraEnv.ID, name)
+	}
+
+	cluster, err := getClusterFromFlags(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("found cluster %s", cluster.ID)
+
+	return ctx.api.FindHostCtx(cmd.Context(), cluster.ID, name)
+}
+
+// Delete a host. Hosts that were discovered through an infra-env
+// are deleted using the v2 infra-env api.
+func deleteHost(ctx *Context, cmd *cobra.Command, host *api.Host) error {
+	if host.InfraEnvID != "" {
+		return ctx.api.DeleteInfraEnvHostCtx(cmd.Context(), host.InfraEnvID, host.ID)
+	}
+
+	return ctx.api.DeleteHostCtx(cmd.Context(), host.ClusterID, host.ID)
+}
+
 func NewCmdHostShow(ctx *Context) *cobra.Command {
 	cmd := cobra.Command{
-		Use:           "show --cluster <cluster_name_or_id> <host_name_or_id>",
+		Use:           "show (--cluster <cluster_name_or_id> | --infra-env <infra_env_name_or_id>) <host_name_or_id>",
 		Short:         "Show details for a single host",
 		Args:          cobra.ExactArgs(1),
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cluster, err := getClusterFromFlags(ctx, cmd)
+			host, err := findHostFromFlags(ctx, cmd, args[0])
 			if err != nil {
 				return err
 			}
-			log.Debugf("found cluster %s", cluster.ID)
 
-			host, err := ctx.api.FindHost(cluster.ID, args[0])
+			inventory, err := host.GetInventory()
 			if err != nil {
 				return err
 			}
 
-			inventory, err := host.GetInventory()
+			return printOutput(cmd, host, func(w io.Writer, wide bool) error {
+				fmt.Fprintf(w, "ID\t%s\n", host.ID)
+				fmt.Fprintf(w, "Manufacturer\t%s\n", inventory.SystemVendor.Manufacturer)
+				fmt.Fprintf(w, "Model\t%s\n", inventory.SystemVendor.ProductName)
+				fmt.Fprintf(w, "Serial\t%s\n", inventory.SystemVendor.SerialNumber)
+				fmt.Fprintf(w, "Role\t%s\n", host.Role)
+				fmt.Fprintf(w, "Status\t%s\n", host.Status)
+				if wide {
+					fmt.Fprintf(w, "StatusInfo\t%s\n", host.StatusInfo)
+				}
+				fmt.Fprintf(w, "Stage\t%s\n", host.HostProgress.CurrentStage)
+				fmt.Fprintf(w, "BMC Address\t%s\n", inventory.BmcAddress)
+				fmt.Fprintf(w, "Architecture\t%s\n", inventory.CPU.Architecture)
+				fmt.Fprintf(w, "CPU Model\t%s\n", inventory.CPU.ModelName)
+				if wide {
+					fmt.Fprintf(w, "CPU Count\t%d\n", inventory.CPU.Count)
+				}
+				fmt.Fprintf(w, "Memory\t%d\n", inventory.Memory.PhysicalBytes/1024/1024/1024)
+				fmt.Fprintf(w, "Interfaces\n")
+				for _, iface := range inventory.Interfaces {
+					var speed string
+
+					if iface.SpeedMbps > 0 {
+						speed = fmt.Sprintf("%d", iface.SpeedMbps)
+					} else {
+						speed = "-"
+					}
+
+					addresses := strings.Join(append(iface.Ipv4Addresses, iface.Ipv6Addresses...), " ")
+					fmt.Fprintf(w,
+						"\t%s\t%s\t%d\t%s\t%s\n",
+						iface.Name, iface.MacAddress, iface.Mtu, speed, addresses)
+				}
+
+				fmt.Fprintf(w, "Disks\n")
+				for _, disk := range inventory.Disks {
+					// The wide format shows all disks, not just the ones
+					// we can install to.
+					if !disk.Bootable && !wide {
+						continue
+					}
+
+					size := disk.SizeBytes / 1024 / 1024 / 1024
+					fmt.Fprintf(w, "\t%s\t%s\t%s\t%s\t%d",
+						disk.Name, disk.Serial, disk.Vendor, disk.Model, size)
+					if wide {
+						fmt.Fprintf(w, "\t%s\t%s", disk.DriveType, disk.ByID)
+					}
+					fmt.Fprintln(w)
+				}
+
+				return nil
+			})
+		},
+	}
+
+	addOutputFlag(&cmd)
+
+	return &cmd
+}
+
+func NewCmdHostValidations(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "validations (--cluster <cluster_name_or_id> | --infra-env <infra_env_name_or_id>) <host_name_or_id>",
+		Short:         "Show host validation results",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			host, err := findHostFromFlags(ctx, cmd, args[0])
 			if err != nil {
 				return err
 			}
 
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-			fmt.Fprintf(w, "ID\t%s\n", host.ID)
-			fmt.Fprintf(w, "Manufacturer\t%s\n", inventory.SystemVendor.Manufacturer)
-			fmt.Fprintf(w, "Model\t%s\n", inventory.SystemVendor.ProductName)
-			fmt.Fprintf(w, "Serial\t%s\n", inventory.SystemVendor.SerialNumber)
-			fmt.Fprintf(w, "Role\t%s\n", host.Role)
-			fmt.Fprintf(w, "Status\t%s\n", host.Status)
-			fmt.Fprintf(w, "Stage\t%s\n", host.HostProgress.CurrentStage)
-			fmt.Fprintf(w, "BMC Address\t%s\n", inventory.BmcAddress)
-			fmt.Fprintf(w, "Architecture\t%s\n", inventory.CPU.Architecture)
-			fmt.Fprintf(w, "CPU Model\t%s\n", inventory.CPU.ModelName)
-			fmt.Fprintf(w, "Memory\t%d\n", inventory.Memory.PhysicalBytes/1024/1024/1024)
-			fmt.Fprintf(w, "Interfaces\n")
-			for _, iface := range inventory.Interfaces {
-				var speed string
+			info, err := host.GetValidationsInfo()
+			if err != nil {
+				return err
+			}
 
-				if iface.SpeedMbps > 0 {
-					speed = fmt.Sprintf("%d", iface.SpeedMbps)
-				} else {
-					speed = "-"
-				}
+			validations, err := getValidationsFromFlags(cmd, info)
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd, validations, validationsTable(validations))
+		},
+	}
 
-				addresses := strings.Join(iface.Ipv4Addresses, " ")
-				fmt.Fprintf(w,
-					"\t%s\t%s\t%d\t%s\t%s\n",
-					iface.Name, iface.MacAddress, iface.Mtu, speed, addresses)
+	addValidationsFlags(&cmd)
+
+	return &cmd
+}
+
+func NewCmdHostLogs(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "logs (--cluster <cluster_name_or_id> | --infra-env <infra_env_name_or_id>) <host_name_or_id> [--dest <dir>] [--extract]",
+		Short:         "Download logs for a single host",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			host, err := findHostFromFlags(ctx, cmd, args[0])
+			if err != nil {
+				return err
 			}
 
-			fmt.Fprintf(w, "Disks\n")
-			for _, disk := range inventory.Disks {
-				if !disk.Bootable {
-					continue
-				}
+			if host.ClusterID == "" {
+				return fmt.Errorf("host %s is not bound to a cluster", hostDisplayName(host))
+			}
 
-				size := disk.SizeBytes / 1024 / 1024 / 1024
-				fmt.Fprintf(w, "\t%s\t%s\t%s\t%s\t%d\n",
-					disk.Name, disk.Serial, disk.Vendor, disk.Model, size)
+			cluster, err := ctx.api.GetClusterCtx(cmd.Context(), host.ClusterID)
+			if err != nil {
+				return err
 			}
 
-			w.Flush()
+			dest, err := cmd.Flags().GetString("dest")
+			if err != nil {
+				return err
+			}
 
-			return nil
+			extract, err := cmd.Flags().GetBool("extract")
+			if err != nil {
+				return err
+			}
+
+			return downloadHostLogs(ctx, cmd, cluster, host, dest, extract)
 		},
 	}
 
+	addLogsFlags(&cmd)
+
 	return &cmd
 }
 
-func listHosts(hosts []api.Host) error {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-	for _, host := range hosts {
-		inventory, err := host.GetInventory()
-		if err != nil {
-			return err
+// Return a table function that lists hosts, one per line.
+func hostTable(hosts []api.Host) tableFunc {
+	return func(w io.Writer, wide bool) error {
+		for _, host := range hosts {
+			inventory, err := host.GetInventory()
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(
+				w,
+				"%s\t%s\t%s\t%s\t%s",
+				host.ID, host.RequestedHostname, host.Role,
+				inventory.BmcAddress, host.Status,
+			)
+			if wide {
+				fmt.Fprintf(
+					w,
+					"\t%s\t%s\t%d\t%d",
+					inventory.SystemVendor.SerialNumber,
+					inventory.SystemVendor.ProductName,
+					inventory.CPU.Count,
+					inventory.Memory.PhysicalBytes/1024/1024/1024,
+				)
+			}
+			fmt.Fprintln(w)
 		}
 
-		fmt.Fprintf(
-			w,
-			"%s\t%s\t%s\t%s\t%s\n",
-			host.ID, host.RequestedHostname, host.Role,
-			inventory.BmcAddress, host.Status,
-		)
+		return nil
 	}
-	w.Flush()
-
-	return nil
 }
 
 func NewCmdHostList(ctx *Context) *cobra.Command {
@@ -112,15 +278,17 @@ func NewCmdHostList(ctx *Context) *cobra.Command {
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cluster, err := getClusterFromFlags(ctx, cmd)
+			hosts, err := getHostsFromFlags(ctx, cmd)
 			if err != nil {
 				return err
 			}
 
-			return listHosts(cluster.Hosts)
+			return printOutput(cmd, hosts, hostTable(hosts))
 		},
 	}
 
+	addOutputFlag(&cmd)
+
 	return &cmd
 }
 
@@ -135,19 +303,14 @@ func NewCmdHostDelete(ctx *Context) *cobra.Command {
 				return fmt.Errorf("no hostnames provided")
 			}
 
-			cluster, err := getClusterFromFlags(ctx, cmd)
-			if err != nil {
-				return err
-			}
-
 			for _, name := range args {
-				host, err := ctx.api.FindHost(cluster.ID, name)
+				host, err := findHostFromFlags(ctx, cmd, name)
 				if err != nil {
 					return err
 				}
 
 				log.Infof("deleting host %s (%s)", name, host.ID)
-				if err := ctx.api.DeleteHost(cluster.ID, host.ID); err != nil {
+				if err := deleteHost(ctx, cmd, host); err != nil {
 					return err
 				}
 			}
@@ -161,180 +324,474 @@ func NewCmdHostDelete(ctx *Context) *cobra.Command {
 
 func NewCmdHostSetName(ctx *Context) *cobra.Command {
 	cmd := cobra.Command{
-		Use:           "set-name --cluster <cluster_id> [<host_id> <name> [...]]",
+		Use:           "set-name --cluster <cluster_id> [<host_id> <name> [...] | --from-file <file> | --template <template>]",
 		Short:         "Set cluster hostnames",
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
+			fromFile, err := cmd.Flags().GetString("from-file")
+			if err != nil {
+				return err
+			}
+
+			tmplText, err := cmd.Flags().GetString("template")
+			if err != nil {
+				return err
+			}
+
+			sortBy, err := cmd.Flags().GetString("sort-by")
+			if err != nil {
+				return err
+			}
+
+			start, err := cmd.Flags().GetInt("start")
+			if err != nil {
+				return err
+			}
+
+			if fromFile != "" && tmplText != "" {
+				return fmt.Errorf("--from-file and --template cannot be used together")
+			}
+			if (fromFile != "" || tmplText != "") && len(args) > 0 {
+				return fmt.Errorf("--from-file and --template cannot be used with hostname arguments")
+			}
+			if fromFile == "" && tmplText == "" && len(args) == 0 {
 				return fmt.Errorf("no hostnames provided")
 			}
 			if len(args)%2 != 0 {
 				return fmt.Errorf("wrong number of arguments")
 			}
 
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			if err != nil {
+				return err
+			}
+			if dryRun && len(args) > 0 {
+				return fmt.Errorf("--dry-run can only be used with --from-file or --template")
+			}
+
 			cluster, err := getClusterFromFlags(ctx, cmd)
 			if err != nil {
 				return err
 			}
 
-			pos := 0
 			var hostnames []api.HostName
-			for pos < len(args) {
-				hostid := args[pos]
-				hostname := args[pos+1]
-				log.Infof("setting hostname %s = %s", hostid, hostname)
-				pos += 2
 
-				spec := api.HostName{
-					ID:       hostid,
-					HostName: hostname,
+			switch {
+			case fromFile != "":
+				mappings, err := readHostNameMappings(fromFile)
+				if err != nil {
+					return err
+				}
+
+				hostnames, err = resolveHostNameMappings(cluster.Hosts, mappings)
+				if err != nil {
+					return err
+				}
+			case tmplText != "":
+				hostnames, err = renderHostNames(cluster, tmplText, sortBy, start)
+				if err != nil {
+					return err
+				}
+			default:
+				for pos := 0; pos < len(args); pos += 2 {
+					host, err := cluster.FindHost(args[pos])
+					if err != nil {
+						return err
+					}
+
+					hostnames = append(hostnames, api.HostName{
+						ID:       host.ID,
+						HostName: args[pos+1],
+					})
+				}
+			}
+
+			// Names given on the command line are applied as they
+			// are; names from a file or a template are shown first.
+			if len(args) == 0 {
+				if err := writeOutput(cmd.OutOrStdout(), "table", hostnames, hostNameTable(cluster, hostnames)); err != nil {
+					return err
+				}
+
+				ok, err := confirmFromFlags(cmd)
+				if err != nil {
+					return err
+				}
+				if !ok && !dryRun {
+					return fmt.Errorf("hostnames not changed")
 				}
+				if !ok {
+					return nil
+				}
+			}
 
-				hostnames = append(hostnames, spec)
+			for _, hostname := range hostnames {
+				log.Infof("setting hostname %s = %s", hostname.ID, hostname.HostName)
 			}
 
-			if err := ctx.api.SetHostnames(cluster.ID, hostnames); err != nil {
+			if err := ctx.api.SetHostnamesCtx(cmd.Context(), cluster.ID, hostnames); err != nil {
 				return err
 			}
 			return nil
 		},
 	}
 
+	cmd.Flags().String("from-file", "", "Read hostnames from a CSV or YAML mapping file")
+	cmd.Flags().String("template", "", "Compute hostnames from a template (e.g. 'worker-{{.Index}}')")
+	cmd.Flags().String("sort-by", "mac", "With --template, number hosts in this order: mac, serial, bmc_address, role or hostname")
+	cmd.Flags().Int("start", 0, "With --template, the first index")
+	addConfirmFlags(&cmd)
+
 	return &cmd
 }
 
-func findHostByMac(hosts []api.Host, value string) []api.Host {
-	var work []api.Host
+func NewCmdHostSetRole(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "set-role --cluster <cluster> [<host> <role> [...] | --role <role> <host> [...]]",
+		Short:         "Set host roles",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("no hosts provided")
+			}
 
-	log.Debugf("searching for mac = %s", value)
+			role, err := cmd.Flags().GetString("role")
+			if err != nil {
+				return err
+			}
 
-	for _, host := range hosts {
-		inventory, err := host.GetInventory()
-		if err != nil {
-			continue
-		}
+			cluster, err := getClusterFromFlags(ctx, cmd)
+			if err != nil {
+				return err
+			}
 
-		for _, iface := range inventory.Interfaces {
-			log.Debugf("want %s, have %s", value, iface.MacAddress)
-			if iface.MacAddress == value {
-				work = append(work, host)
-				break
+			// With --role, every argument is a host. Otherwise the
+			// arguments are <host> <role> pairs.
+			var names, roles []string
+			if role != "" {
+				for _, name := range args {
+					names = append(names, name)
+					roles = append(roles, role)
+				}
+			} else {
+				if len(args)%2 != 0 {
+					return fmt.Errorf("wrong number of arguments")
+				}
+				for pos := 0; pos < len(args); pos += 2 {
+					names = append(names, args[pos])
+					roles = append(roles, args[pos+1])
+				}
 			}
-		}
-	}
 
-	return work
-}
+			var hostRoles []api.HostRole
+			for i, name := range names {
+				host, err := cluster.FindHost(name)
+				if err != nil {
+					return err
+				}
 
-func findHostByBmcAddress(hosts []api.Host, value string) []api.Host {
-	var work []api.Host
+				log.Infof("setting role %s = %s", hostDisplayName(host), roles[i])
+				hostRoles = append(hostRoles, api.HostRole{
+					ID:   host.ID,
+					Role: roles[i],
+				})
+			}
 
-	log.Debugf("searching for bmc address = %s", value)
+			if err := cluster.ValidateHostRoles(hostRoles); err != nil {
+				return err
+			}
 
-	for _, host := range hosts {
-		inventory, err := host.GetInventory()
-		if err != nil {
-			continue
-		}
+			updateParams := api.ClusterUpdateParams{
+				HostsRoles: hostRoles,
+			}
 
-		if inventory.BmcAddress == value {
-			work = append(work, host)
-		}
+			if _, err := ctx.api.PatchClusterCtx(cmd.Context(), cluster.ID, &updateParams); err != nil {
+				return err
+			}
+
+			return nil
+		},
 	}
 
-	return work
+	cmd.Flags().String("role", "", "Assign this role to all of the named hosts")
+
+	return &cmd
 }
 
-func findHostByVendor(hosts []api.Host, value string) []api.Host {
-	var work []api.Host
+// A change to the installation disk of a host, for previewing before
+// we apply it.
+type diskChange struct {
+	Host    *api.Host
+	Current string
+	Disk    *api.Disks
+}
 
-	for _, host := range hosts {
-		inventory, err := host.GetInventory()
-		if err != nil {
-			continue
+// Return the name of the disk the host will currently install to.
+func currentInstallationDisk(host *api.Host, inventory *api.HostInventory) string {
+	for _, disk := range inventory.Disks {
+		if disk.ID == host.InstallationDiskID {
+			return disk.Name
 		}
+	}
 
-		if inventory.SystemVendor.Manufacturer == value {
-			work = append(work, host)
+	return host.InstallationDiskPath
+}
+
+func diskChangeTable(changes []diskChange) tableFunc {
+	return func(w io.Writer, wide bool) error {
+		fmt.Fprintln(w, "HOST\tCURRENT\tNEW\tTYPE\tSIZE\tSERIAL")
+		for _, change := range changes {
+			newDisk := change.Disk.Name
+			if newDisk == change.Current {
+				newDisk = "(unchanged)"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%dGB\t%s\n",
+				hostDisplayName(change.Host), change.Current, newDisk,
+				change.Disk.DriveType, change.Disk.SizeBytes/1000/1000/1000,
+				change.Disk.Serial)
 		}
-	}
 
-	return work
+		return nil
+	}
 }
 
-func findHostByProduct(hosts []api.Host, value string) []api.Host {
-	var work []api.Host
+// Build a disk selection policy from the command line options.
+func getDiskPolicyFromFlags(cmd *cobra.Command) (*api.DiskPolicy, error) {
+	var policy api.DiskPolicy
+	var err error
 
-	for _, host := range hosts {
-		inventory, err := host.GetInventory()
+	policy.Select, err = cmd.Flags().GetString("select")
+	if err != nil {
+		return nil, err
+	}
+
+	policy.DriveType, err = cmd.Flags().GetString("drive-type")
+	if err != nil {
+		return nil, err
+	}
+
+	for flag, size := range map[string]*int64{
+		"min-size": &policy.MinSize,
+		"max-size": &policy.MaxSize,
+	} {
+		value, err := cmd.Flags().GetString(flag)
 		if err != nil {
+			return nil, err
+		}
+		if value == "" {
 			continue
 		}
 
-		if inventory.SystemVendor.ProductName == value {
-			work = append(work, host)
+		*size, err = api.ParseSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flag, err)
 		}
 	}
 
-	return work
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
 }
 
-func NewCmdHostFind(ctx *Context) *cobra.Command {
+func NewCmdHostSetDisk(ctx *Context) *cobra.Command {
 	cmd := cobra.Command{
-		Use:           "find --cluster <cluster_id> -m name=value [-m ...]",
-		Short:         "Find hosts matching criteria",
-		Args:          cobra.NoArgs,
+		Use:           "set-disk --cluster <cluster> [<host> <disk> [...] | --select <smallest|largest> [<host> ...]]",
+		Short:         "Select host installation disks",
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			match, err := cmd.Flags().GetStringArray("match")
+			selection, err := cmd.Flags().GetString("select")
 			if err != nil {
 				return err
 			}
 
+			for _, flag := range []string{"drive-type", "min-size", "max-size"} {
+				if cmd.Flags().Changed(flag) && selection == "" {
+					return fmt.Errorf("--%s requires --select", flag)
+				}
+			}
+
 			cluster, err := getClusterFromFlags(ctx, cmd)
 			if err != nil {
 				return err
 			}
 
-			selected := cluster.Hosts
-			for _, spec := range match {
-				parsed := strings.SplitN(spec, "=", 2)
-				name := parsed[0]
-				value := parsed[1]
+			var changes []diskChange
 
-				if len(parsed) != 2 {
-					return fmt.Errorf("invalid match specification: %s",
-						spec)
+			addChange := func(host *api.Host, choose func(*api.HostInventory) (*api.Disks, error)) error {
+				inventory, err := host.GetInventory()
+				if err != nil {
+					return fmt.Errorf("host %s: failed to read inventory: %w", hostDisplayName(host), err)
 				}
 
-				log.Debugf("searching for name=%s, val=%s\n",
-					parsed[0], parsed[1])
+				disk, err := choose(inventory)
+				if err != nil {
+					return fmt.Errorf("host %s: %w", hostDisplayName(host), err)
+				}
 
-				switch name {
-				case "mac":
-					selected = findHostByMac(selected, value)
-				case "bmc_address", "bmc-address":
-					selected = findHostByBmcAddress(selected, value)
-				case "vendor":
-					selected = findHostByVendor(selected, value)
-				case "product":
-					selected = findHostByProduct(selected, value)
-				default:
-					return fmt.Errorf("unsupported search key: %s", name)
+				changes = append(changes, diskChange{
+					Host:    host,
+					Current: currentInstallationDisk(host, inventory),
+					Disk:    disk,
+				})
+
+				return nil
+			}
+
+			if selection != "" {
+				// With --select, the arguments (if any) are the hosts
+				// to change; otherwise we change all of them.
+				policy, err := getDiskPolicyFromFlags(cmd)
+				if err != nil {
+					return err
+				}
+
+				var hosts []*api.Host
+				if len(args) == 0 {
+					for i := range cluster.Hosts {
+						hosts = append(hosts, &cluster.Hosts[i])
+					}
+				}
+				for _, name := range args {
+					host, err := cluster.FindHost(name)
+					if err != nil {
+						return err
+					}
+					hosts = append(hosts, host)
+				}
+
+				for _, host := range hosts {
+					if err := addChange(host, policy.SelectDisk); err != nil {
+						return err
+					}
+				}
+			} else {
+				if len(args) == 0 {
+					return fmt.Errorf("no hosts provided")
+				}
+				if len(args)%2 != 0 {
+					return fmt.Errorf("wrong number of arguments")
+				}
+
+				for pos := 0; pos < len(args); pos += 2 {
+					host, err := cluster.FindHost(args[pos])
+					if err != nil {
+						return err
+					}
+
+					ref := args[pos+1]
+					err = addChange(host, func(inventory *api.HostInventory) (*api.Disks, error) {
+						disk, err := inventory.FindDisk(ref)
+						if err != nil {
+							return nil, err
+						}
+						if disk.IsInstallationMedia {
+							return nil, fmt.Errorf("disk %s is the installation media", ref)
+						}
+						return disk, nil
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := writeOutput(cmd.OutOrStdout(), "table", changes, diskChangeTable(changes)); err != nil {
+				return err
+			}
+
+			var updateParams api.ClusterUpdateParams
+			for _, change := range changes {
+				if change.Disk.ID == change.Host.InstallationDiskID {
+					continue
+				}
+
+				updateParams.DisksSelectedConfig = append(updateParams.DisksSelectedConfig, api.HostDisksConfig{
+					ID:          change.Host.ID,
+					DisksConfig: []api.DiskConfig{{ID: change.Disk.ID, Role: "install"}},
+				})
+			}
+
+			if len(updateParams.DisksSelectedConfig) == 0 {
+				log.Infof("no changes to make")
+				return nil
+			}
+
+			ok, err := confirmFromFlags(cmd)
+			if err != nil || !ok {
+				return err
+			}
+
+			log.Infof("setting installation disks for %d host(s)", len(updateParams.DisksSelectedConfig))
+			if _, err := ctx.api.PatchClusterCtx(cmd.Context(), cluster.ID, &updateParams); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("select", "", "Select disks by policy: smallest or largest matching disk")
+	cmd.Flags().String("drive-type", "", "With --select, only consider disks of this type (e.g. SSD or HDD)")
+	cmd.Flags().String("min-size", "", "With --select, only consider disks at least this large (e.g. 120G or 112GiB)")
+	cmd.Flags().String("max-size", "", "With --select, only consider disks at most this large (e.g. 2T or 1.8TiB)")
+	addConfirmFlags(&cmd)
+
+	return &cmd
+}
+
+func NewCmdHostFind(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "find --cluster <cluster_id> [<query> | -m <query> [-m ...]]",
+		Short:         "Find hosts matching criteria",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			match, err := cmd.Flags().GetStringArray("match")
+			if err != nil {
+				return err
+			}
+
+			// The positional arguments are one query, so that it
+			// doesn't have to be quoted. Each --match is another
+			// query, and hosts must match all of them.
+			if len(args) > 0 {
+				match = append(match, strings.Join(args, " "))
+			}
+
+			var queries []queryNode
+			for _, spec := range match {
+				query, err := parseQuery(spec)
+				if err != nil {
+					return err
 				}
+				queries = append(queries, query)
+			}
+
+			selected, err := getHostsFromFlags(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			for _, query := range queries {
+				selected = filterHosts(selected, query)
 			}
 
 			if len(selected) > 0 {
-				return listHosts(selected)
+				return printOutput(cmd, selected, hostTable(selected))
 			}
 
 			return fmt.Errorf("no hosts matched your criteria")
 		},
 	}
 
-	cmd.Flags().StringArrayP("match", "m", nil, "match criteria")
+	cmd.Flags().StringArrayP("match", "m", nil, "match criteria (a query, e.g. 'memory>=64GiB and disk.type=SSD')")
+	addOutputFlag(&cmd)
 
 	return &cmd
 }
@@ -383,8 +840,10 @@ func NewCmdHostWaitForStatus(ctx *Context) *cobra.Command {
 			log.Infof("waiting for %d hosts in cluster %s to reach status %s",
 				hostcount, cluster.Name, desired_status)
 
+			waitctx, cancel := withTimeout(cmd.Context(), timeout)
+			defer cancel()
+
 			retry_count := 0
-			time_start := time.Now()
 			for {
 				hosts_with_status := 0
 
@@ -401,19 +860,17 @@ func NewCmdHostWaitForStatus(ctx *Context) *cobra.Command {
 					break
 				}
 
-				if timeout > 0 && time.Since(time_start).Seconds() > float64(timeout) {
-					return fmt.Errorf("timed out waiting for status")
-				}
-
 				retry_count++
 				if retries > 0 && retry_count > retries {
 					return fmt.Errorf("too many retries waiting for status")
 				}
 
-				time.Sleep(time.Duration(interval) * time.Second)
-				cluster, err = getClusterFromFlags(ctx, cmd)
+				if err := sleepCtx(waitctx, time.Duration(interval)*time.Second); err != nil {
+					return waitError(err)
+				}
+				cluster, err = ctx.api.GetClusterCtx(waitctx, cluster.ID)
 				if err != nil {
-					return err
+					return waitError(err)
 				}
 			}
 
@@ -436,11 +893,16 @@ func NewCmdHost(ctx *Context) *cobra.Command {
 	}
 
 	cmd.PersistentFlags().String("cluster", "", "cluster id or name")
+	cmd.PersistentFlags().String("infra-env", "", "infra-env id or name (instead of --cluster)")
 
 	cmd.AddCommand(
 		NewCmdHostList(ctx),
 		NewCmdHostSetName(ctx),
+		NewCmdHostSetRole(ctx),
+		NewCmdHostSetDisk(ctx),
 		NewCmdHostShow(ctx),
+		NewCmdHostValidations(ctx),
+		NewCmdHostLogs(ctx),
 		NewCmdHostDelete(ctx),
 		NewCmdHostFind(ctx),
 		NewCmdHostWaitForStatus(ctx),
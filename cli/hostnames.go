@@ -0,0 +1,398 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/larsks/oaitool/api"
+	"gopkg.in/yaml.v2"
+)
+
+// A row in a hostname mapping file. Name is the hostname to assign;
+// the other fields identify the host. If more than one of them is
+// set, the host must match all of them.
+type hostNameMapping struct {
+	Mac        string `yaml:"mac,omitempty"`
+	BmcAddress string `yaml:"bmc_address,omitempty"`
+	Serial     string `yaml:"serial,omitempty"`
+	Hostname   string `yaml:"hostname,omitempty"`
+	Name       string `yaml:"name"`
+
+	// Where the mapping came from, for error messages.
+	source string
+}
+
+// A field that identifies a host in a mapping. The key is the name of
+// the corresponding host find field.
+type hostNameCriterion struct {
+	key, value string
+}
+
+// Return the criteria that are set in a mapping.
+func (mapping *hostNameMapping) criteria() []hostNameCriterion {
+	var criteria []hostNameCriterion
+
+	for _, criterion := range []hostNameCriterion{
+		{"mac", mapping.Mac},
+		{"bmc_address", mapping.BmcAddress},
+		{"serial", mapping.Serial},
+		{"hostname", mapping.Hostname},
+	} {
+		if criterion.value != "" {
+			criteria = append(criteria, criterion)
+		}
+	}
+
+	return criteria
+}
+
+// Describe the criteria in a mapping, e.g. "mac=52:54:00:00:00:01".
+func (mapping *hostNameMapping) String() string {
+	var criteria []string
+
+	for _, criterion := range mapping.criteria() {
+		criteria = append(criteria, fmt.Sprintf("%s=%s", criterion.key, criterion.value))
+	}
+
+	return strings.Join(criteria, ",")
+}
+
+// Return a query that matches the hosts that meet all of the criteria
+// in a mapping. Values are compared exactly rather than as glob
+// patterns, since serial numbers and the like may contain glob
+// characters.
+func (mapping *hostNameMapping) query() queryNode {
+	var query queryNode
+
+	for _, criterion := range mapping.criteria() {
+		node := &queryCompare{
+			name:  criterion.key,
+			field: queryFields[criterion.key],
+			op:    "=",
+			value: criterion.value,
+		}
+
+		if query == nil {
+			query = node
+		} else {
+			query = &queryAnd{query, node}
+		}
+	}
+
+	return query
+}
+
+// Read hostname mappings from a file. Files with a .csv extension are
+// CSV files with a header row naming the columns (mac, bmc_address,
+// serial, hostname and name); anything else is read as a YAML (or
+// JSON) list of mappings with the same keys.
+func readHostNameMappings(path string) ([]hostNameMapping, error) {
+	var mappings []hostNameMapping
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		mappings, err = parseHostNameMappingsCSV(string(data))
+	} else {
+		err = yaml.UnmarshalStrict(data, &mappings)
+		for i := range mappings {
+			mappings[i].source = fmt.Sprintf("entry %d", i+1)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if len(mappings) == 0 {
+		return nil, fmt.Errorf("no hostnames found in %s", path)
+	}
+
+	for _, mapping := range mappings {
+		if mapping.Name == "" {
+			return nil, fmt.Errorf("%s: %s: no name", path, mapping.source)
+		}
+		if mapping.String() == "" {
+			return nil, fmt.Errorf("%s: %s: no mac, bmc_address, serial or hostname", path, mapping.source)
+		}
+	}
+
+	return mappings, nil
+}
+
+func parseHostNameMappingsCSV(data string) ([]hostNameMapping, error) {
+	var mappings []hostNameMapping
+
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	for _, column := range header {
+		switch strings.TrimSpace(column) {
+		case "mac", "bmc_address", "serial", "hostname", "name":
+		default:
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+	}
+
+	for i, record := range records[1:] {
+		mapping := hostNameMapping{
+			source: fmt.Sprintf("line %d", i+2),
+		}
+
+		for j, value := range record {
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(header[j]) {
+			case "mac":
+				mapping.Mac = value
+			case "bmc_address":
+				mapping.BmcAddress = value
+			case "serial":
+				mapping.Serial = value
+			case "hostname":
+				mapping.Hostname = value
+			case "name":
+				mapping.Name = value
+			}
+		}
+
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, nil
+}
+
+// Find the host for each mapping, using the same matchers as host
+// find. Every problem (no match, more than one match, a host matched
+// by more than one mapping, or a name used twice) is reported, and if
+// there are any we return an error rather than a partial result.
+func resolveHostNameMappings(hosts []api.Host, mappings []hostNameMapping) ([]api.HostName, error) {
+	var hostnames []api.HostName
+	var problems []string
+
+	hostSource := make(map[string]string)
+	nameSource := make(map[string]string)
+
+	for _, mapping := range mappings {
+		selected := filterHosts(hosts, mapping.query())
+
+		switch len(selected) {
+		case 0:
+			problems = append(problems, fmt.Sprintf("%s: no host matches %s", mapping.source, &mapping))
+			continue
+		case 1:
+		default:
+			var ids []string
+			for _, host := range selected {
+				ids = append(ids, host.ID)
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s matches %d hosts (%s)",
+				mapping.source, &mapping, len(selected), strings.Join(ids, ", ")))
+			continue
+		}
+
+		host := selected[0]
+
+		if other, ok := hostSource[host.ID]; ok {
+			problems = append(problems, fmt.Sprintf("%s: host %s was already matched by %s",
+				mapping.source, host.ID, other))
+			continue
+		}
+		hostSource[host.ID] = mapping.source
+
+		if other, ok := nameSource[mapping.Name]; ok {
+			problems = append(problems, fmt.Sprintf("%s: name %s is already used by %s",
+				mapping.source, mapping.Name, other))
+			continue
+		}
+		nameSource[mapping.Name] = mapping.source
+
+		hostnames = append(hostnames, api.HostName{
+			ID:       host.ID,
+			HostName: mapping.Name,
+		})
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("cannot set hostnames:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	return hostnames, nil
+}
+
+// The data available to hostname templates.
+type hostNameTemplateData struct {
+	// Position of the host in the sorted list of hosts, counting from
+	// --start.
+	Index int
+
+	// Position of the host among the hosts with the same role,
+	// counting from --start.
+	RoleIndex int
+
+	// The role of the host, with bootstrap reported as master and no
+	// role as auto-assign (see api.NormalizeHostRole).
+	Role       string
+	Mac        string
+	Serial     string
+	BmcAddress string
+	Host       *api.Host
+	Inventory  *api.HostInventory
+	Cluster    *api.Cluster
+}
+
+var supportedHostSortKeys = []string{
+	"mac",
+	"serial",
+	"bmc_address",
+	"role",
+	"hostname",
+}
+
+// Roles in the order we sort them: control plane hosts first.
+var hostRoleOrder = map[string]int{
+	"master":      0,
+	"auto-assign": 1,
+	"worker":      2,
+}
+
+func newHostNameTemplateData(cluster *api.Cluster, host *api.Host) (*hostNameTemplateData, error) {
+	inventory, err := host.GetInventory()
+	if err != nil {
+		return nil, fmt.Errorf("host %s: failed to read inventory: %w", hostDisplayName(host), err)
+	}
+
+	data := hostNameTemplateData{
+		Role:       api.NormalizeHostRole(host.Role),
+		Serial:     inventory.SystemVendor.SerialNumber,
+		BmcAddress: inventory.BmcAddress,
+		Host:       host,
+		Inventory:  inventory,
+		Cluster:    cluster,
+	}
+
+	if len(inventory.Interfaces) > 0 {
+		data.Mac = strings.ToLower(inventory.Interfaces[0].MacAddress)
+	}
+
+	return &data, nil
+}
+
+// Return the value of a sort key for a host. Roles sort in
+// hostRoleOrder.
+func (data *hostNameTemplateData) sortKey(key string) string {
+	switch key {
+	case "mac":
+		return data.Mac
+	case "serial":
+		return data.Serial
+	case "bmc_address":
+		return data.BmcAddress
+	case "role":
+		return fmt.Sprintf("%d", hostRoleOrder[data.Role])
+	case "hostname":
+		return data.Host.RequestedHostname
+	default:
+		return ""
+	}
+}
+
+// Compute a hostname for each host in the cluster by rendering a
+// template. Hosts are numbered in the order given by sortBy; ties are
+// broken by MAC address and then host id, so the result doesn't depend
+// on the order in which the api returns hosts.
+func renderHostNames(cluster *api.Cluster, tmplText, sortBy string, start int) ([]api.HostName, error) {
+	if !api.ValInList(sortBy, supportedHostSortKeys) {
+		return nil, fmt.Errorf("invalid sort key %q: must be one of %v", sortBy, supportedHostSortKeys)
+	}
+
+	tmpl, err := template.New("hostname").Option("missingkey=error").Parse(tmplText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hostname template: %w", err)
+	}
+
+	var hosts []*hostNameTemplateData
+	for i := range cluster.Hosts {
+		data, err := newHostNameTemplateData(cluster, &cluster.Hosts[i])
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, data)
+	}
+
+	sort.SliceStable(hosts, func(i, j int) bool {
+		for _, key := range []string{sortBy, "mac"} {
+			if a, b := hosts[i].sortKey(key), hosts[j].sortKey(key); a != b {
+				return a < b
+			}
+		}
+		return hosts[i].Host.ID < hosts[j].Host.ID
+	})
+
+	var hostnames []api.HostName
+	roleCount := make(map[string]int)
+	nameSource := make(map[string]string)
+
+	for i, data := range hosts {
+		data.Index = start + i
+		data.RoleIndex = start + roleCount[data.Role]
+		roleCount[data.Role]++
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("host %s: %w", data.Host.ID, err)
+		}
+
+		name := strings.TrimSpace(buf.String())
+		if name == "" {
+			return nil, fmt.Errorf("host %s: template produced an empty hostname", data.Host.ID)
+		}
+		if other, ok := nameSource[name]; ok {
+			return nil, fmt.Errorf("hosts %s and %s would both be named %s", other, data.Host.ID, name)
+		}
+		nameSource[name] = data.Host.ID
+
+		hostnames = append(hostnames, api.HostName{
+			ID:       data.Host.ID,
+			HostName: name,
+		})
+	}
+
+	return hostnames, nil
+}
+
+func hostNameTable(cluster *api.Cluster, hostnames []api.HostName) tableFunc {
+	return func(w io.Writer, wide bool) error {
+		fmt.Fprintln(w, "ID\tROLE\tCURRENT\tNEW")
+		for _, hostname := range hostnames {
+			host, err := cluster.FindHost(hostname.ID)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				host.ID, host.Role, host.RequestedHostname, hostname.HostName)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,382 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/larsks/oaitool/api"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Return the id of the infra-env associated with a cluster. We first
+// look at the hosts in the cluster (which know which infra-env they
+// booted from). If none of them do and search is true, we ask the api
+// for infra-envs bound to the cluster. Returns an empty string if we
+// can't find one.
+func getClusterInfraEnvID(ctx *Context, cmd *cobra.Command, cluster *api.Cluster, search bool) string {
+	for _, host := range cluster.Hosts {
+		if host.InfraEnvID != "" {
+			return host.InfraEnvID
+		}
+	}
+
+	if !search {
+		return ""
+	}
+
+	infraEnvs, err := ctx.api.ListInfraEnvsCtx(cmd.Context(), cluster.ID)
+	if err != nil {
+		log.Debugf("unable to list infra-envs for cluster %s: %v", cluster.ID, err)
+		return ""
+	}
+
+	if len(infraEnvs) > 0 {
+		return infraEnvs[0].ID
+	}
+
+	return ""
+}
+
+// Return the discovery image url for an infra-env, asking the api
+// for a new one if the infra-env doesn't have one.
+func getInfraEnvImageUrl(ctx *Context, cmd *cobra.Command, infraEnv *api.InfraEnv) (string, error) {
+	if infraEnv.DownloadUrl != "" {
+		return infraEnv.DownloadUrl, nil
+	}
+
+	imageUrl, err := ctx.api.GetInfraEnvImageUrlCtx(cmd.Context(), infraEnv.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if imageUrl.Url == "" {
+		return "", fmt.Errorf("failed to retrieve discovery image url")
+	}
+
+	return imageUrl.Url, nil
+}
+
+func NewCmdInfraEnvList(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "list [--cluster <name_or_id>]",
+		Short:         "List available infra-envs",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var clusterid string
+
+			clustername, err := cmd.Flags().GetString("cluster")
+			if err != nil {
+				return err
+			}
+
+			if clustername != "" {
+				cluster, err := ctx.api.FindClusterCtx(cmd.Context(), clustername)
+				if err != nil {
+					return err
+				}
+				clusterid = cluster.ID
+			}
+
+			infraEnvs, err := ctx.api.ListInfraEnvsCtx(cmd.Context(), clusterid)
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd, infraEnvs, func(w io.Writer, wide bool) error {
+				for _, infraEnv := range infraEnvs {
+					fmt.Fprintf(
+						w,
+						"%s\t%s\t%s\t%s",
+						infraEnv.Name,
+						infraEnv.ID,
+						infraEnv.ClusterID,
+						infraEnv.Type,
+					)
+					if wide {
+						fmt.Fprintf(
+							w,
+							"\t%s\t%s\t%s",
+							infraEnv.OpenshiftVersion,
+							infraEnv.CpuArchitecture,
+							infraEnv.ExpiresAt.Format(time.RFC3339),
+						)
+					}
+					fmt.Fprintln(w)
+				}
+
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().String("cluster", "", "only show infra-envs for this cluster")
+	addOutputFlag(&cmd)
+
+	return &cmd
+}
+
+func NewCmdInfraEnvShow(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "show <name_or_id>",
+		Short:         "Show details for a single infra-env",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			infraEnv, err := ctx.api.FindInfraEnvCtx(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			use_json, err := cmd.Flags().GetBool("json")
+			if err != nil {
+				return err
+			}
+
+			if use_json {
+				infraEnvJson, err := infraEnv.ToJSON()
+				if err != nil {
+					return err
+				}
+
+				os.Stdout.Write(infraEnvJson)
+				return nil
+			}
+
+			return printOutput(cmd, infraEnv, func(w io.Writer, wide bool) error {
+				fmt.Fprintf(w, "Name\t%s\n", infraEnv.Name)
+				fmt.Fprintf(w, "ID\t%s\n", infraEnv.ID)
+				fmt.Fprintf(w, "ClusterID\t%s\n", infraEnv.ClusterID)
+				fmt.Fprintf(w, "OpenshiftVersion\t%s\n", infraEnv.OpenshiftVersion)
+				fmt.Fprintf(w, "CpuArchitecture\t%s\n", infraEnv.CpuArchitecture)
+				fmt.Fprintf(w, "ImageType\t%s\n", infraEnv.Type)
+				fmt.Fprintf(w, "ExpiresAt\t%s\n", infraEnv.ExpiresAt)
+				if wide {
+					fmt.Fprintf(w, "DownloadUrl\t%s\n", infraEnv.DownloadUrl)
+					fmt.Fprintf(w, "CreatedAt\t%s\n", infraEnv.CreatedAt)
+				}
+
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().BoolP("json", "j", false, "Show full JSON data (compact form of --output json)")
+	addOutputFlag(&cmd)
+
+	return &cmd
+}
+
+func NewCmdInfraEnvCreate(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "create <name>",
+		Short:         "Create an infra-env",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			psjson, err := getPullSecretFromFlags(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			sshKey, err := getSshKeyFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			imageType, err := cmd.Flags().GetString("image-type")
+			if err != nil {
+				return err
+			}
+			if !api.ValidateImageType(imageType) {
+				return fmt.Errorf("invalid image type")
+			}
+
+			openshiftVersion, err := cmd.Flags().GetString("openshift-version")
+			if err != nil {
+				return err
+			}
+
+			cpuArchitecture, err := cmd.Flags().GetString("cpu-architecture")
+			if err != nil {
+				return err
+			}
+
+			createParams := api.InfraEnvCreateParams{
+				Name:             args[0],
+				PullSecret:       string(psjson),
+				OpenshiftVersion: openshiftVersion,
+				CpuArchitecture:  cpuArchitecture,
+				ImageType:        imageType,
+				SshAuthorizedKey: sshKey,
+			}
+
+			clustername, err := cmd.Flags().GetString("cluster")
+			if err != nil {
+				return err
+			}
+
+			if clustername != "" {
+				cluster, err := ctx.api.FindClusterCtx(cmd.Context(), clustername)
+				if err != nil {
+					return err
+				}
+				createParams.ClusterID = cluster.ID
+				if createParams.OpenshiftVersion == "" {
+					createParams.OpenshiftVersion = cluster.OpenshiftVersion
+				}
+			}
+
+			log.Infof("creating infra-env %s", createParams.Name)
+			infraEnv, err := ctx.api.CreateInfraEnvCtx(cmd.Context(), &createParams)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%s %s\n", infraEnv.Name, infraEnv.ID)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("cluster", "", "Bind infra-env to this cluster")
+	cmd.Flags().String("pull-secret", "", "Read pull secret from a file")
+	cmd.Flags().String("openshift-version", "", "Set OpenShift version")
+	cmd.Flags().String("cpu-architecture", "", "Set CPU architecture")
+	cmd.Flags().String("ssh-public-key", "", "Public ssh key")
+	cmd.Flags().String("image-type", "minimal-iso", "set discovery image type")
+
+	return &cmd
+}
+
+func NewCmdInfraEnvUpdate(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "update <name_or_id>",
+		Short:         "Update an infra-env",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var updateParams api.InfraEnvUpdateParams
+
+			infraEnv, err := ctx.api.FindInfraEnvCtx(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("image-type") {
+				imageType, err := cmd.Flags().GetString("image-type")
+				if err != nil {
+					return err
+				}
+				if !api.ValidateImageType(imageType) {
+					return fmt.Errorf("invalid image type")
+				}
+				updateParams.ImageType = imageType
+			}
+
+			if cmd.Flags().Changed("ssh-public-key") {
+				updateParams.SshAuthorizedKey, err = getSshKeyFromFlags(cmd)
+				if err != nil {
+					return err
+				}
+			}
+
+			if cmd.Flags().Changed("pull-secret") {
+				psjson, err := getPullSecretFromFlags(ctx, cmd)
+				if err != nil {
+					return err
+				}
+				updateParams.PullSecret = string(psjson)
+			}
+
+			log.Infof("updating infra-env %s", infraEnv.Name)
+			if _, err := ctx.api.UpdateInfraEnvCtx(cmd.Context(), infraEnv.ID, &updateParams); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("pull-secret", "", "Read pull secret from a file")
+	cmd.Flags().String("ssh-public-key", "", "Public ssh key")
+	cmd.Flags().String("image-type", "", "set discovery image type")
+
+	return &cmd
+}
+
+func NewCmdInfraEnvDelete(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "delete <name_or_id>",
+		Short:         "Delete the specified infra-env",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			infraEnv, err := ctx.api.FindInfraEnvCtx(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			log.Infof("deleting infra-env %s", infraEnv.Name)
+			if err := ctx.api.DeleteInfraEnvCtx(cmd.Context(), infraEnv.ID); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	return &cmd
+}
+
+func NewCmdInfraEnvGetImageUrl(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "get-image-url <name_or_id>",
+		Short:         "Get discovery image download url",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			infraEnv, err := ctx.api.FindInfraEnvCtx(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			imageUrl, err := getInfraEnvImageUrl(ctx, cmd, infraEnv)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(imageUrl)
+			return nil
+		},
+	}
+
+	return &cmd
+}
+
+func NewCmdInfraEnv(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "infra-env",
+		Short: "Commands for interacting with infra-envs",
+	}
+
+	cmd.AddCommand(
+		NewCmdInfraEnvList(ctx),
+		NewCmdInfraEnvShow(ctx),
+		NewCmdInfraEnvCreate(ctx),
+		NewCmdInfraEnvUpdate(ctx),
+		NewCmdInfraEnvDelete(ctx),
+		NewCmdInfraEnvGetImageUrl(ctx),
+	)
+
+	return &cmd
+}
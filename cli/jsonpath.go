@@ -0,0 +1,281 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// This is a small implementation of the kubectl flavor of JSONPath,
+// used by the jsonpath and custom-columns output formats. It supports
+// field access (.name), array indexes ([0], [-1]), wildcards ([*] and
+// .*), quoted string literals ({"\n"}) and {range ...}{end} blocks.
+// Templates are evaluated against the generic (map/slice) form of the
+// data, so field names are the names used by the api.
+
+type (
+	jsonPathStep struct {
+		field    string
+		index    int
+		wildcard bool
+		isIndex  bool
+	}
+
+	jsonPathNode struct {
+		text     string
+		literal  bool
+		path     []jsonPathStep
+		children []*jsonPathNode
+
+		// A {range} block, which executes children for each value
+		// selected by path. (The path may be empty, as in {range .},
+		// so we can't tell a range by its path.)
+		isRange bool
+	}
+
+	JSONPath struct {
+		nodes []*jsonPathNode
+	}
+)
+
+// ParseJSONPath parses a template such as "{.name}" or
+// "{range [*]}{.id}{"\n"}{end}".
+func ParseJSONPath(template string) (*JSONPath, error) {
+	var stack [][]*jsonPathNode
+	var ranges []*jsonPathNode
+	var current []*jsonPathNode
+
+	for len(template) > 0 {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			current = append(current, &jsonPathNode{text: template, literal: true})
+			break
+		}
+
+		if start > 0 {
+			current = append(current, &jsonPathNode{text: template[:start], literal: true})
+		}
+
+		end := findClosingBrace(template, start)
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed action in jsonpath template")
+		}
+
+		expr := strings.TrimSpace(template[start+1 : end])
+		template = template[end+1:]
+
+		switch {
+		case expr == "end":
+			if len(ranges) == 0 {
+				return nil, fmt.Errorf("{end} without matching {range}")
+			}
+			node := ranges[len(ranges)-1]
+			ranges = ranges[:len(ranges)-1]
+			node.children = current
+			current = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		case strings.HasPrefix(expr, "range "):
+			path, err := parseJSONPathExpr(strings.TrimSpace(strings.TrimPrefix(expr, "range ")))
+			if err != nil {
+				return nil, err
+			}
+			node := &jsonPathNode{path: path, isRange: true}
+			current = append(current, node)
+			stack = append(stack, current)
+			ranges = append(ranges, node)
+			current = nil
+		case strings.HasPrefix(expr, `"`):
+			text, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string literal %s: %w", expr, err)
+			}
+			current = append(current, &jsonPathNode{text: text, literal: true})
+		default:
+			path, err := parseJSONPathExpr(expr)
+			if err != nil {
+				return nil, err
+			}
+			current = append(current, &jsonPathNode{path: path})
+		}
+	}
+
+	if len(ranges) > 0 {
+		return nil, fmt.Errorf("{range} without matching {end}")
+	}
+
+	return &JSONPath{nodes: current}, nil
+}
+
+// Find the brace that closes the action starting at template[start],
+// skipping over quoted strings.
+func findClosingBrace(template string, start int) int {
+	inQuote := false
+	for i := start + 1; i < len(template); i++ {
+		switch template[i] {
+		case '\\':
+			if inQuote {
+				i++
+			}
+		case '"':
+			inQuote = !inQuote
+		case '}':
+			if !inQuote {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func parseJSONPathExpr(expr string) ([]jsonPathStep, error) {
+	var steps []jsonPathStep
+
+	// $ and @ (the root and current object) are implied.
+	expr = strings.TrimPrefix(strings.TrimPrefix(expr, "$"), "@")
+	for len(expr) > 0 {
+		switch expr[0] {
+		case '.':
+			expr = expr[1:]
+			end := strings.IndexAny(expr, ".[")
+			if end < 0 {
+				end = len(expr)
+			}
+			name := expr[:end]
+			expr = expr[end:]
+			switch name {
+			case "":
+				// Allow "." on its own to mean the current object.
+			case "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			default:
+				steps = append(steps, jsonPathStep{field: name})
+			}
+		case '[':
+			end := strings.Index(expr, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in jsonpath expression")
+			}
+			subscript := strings.Trim(expr[1:end], `'"`)
+			expr = expr[end+1:]
+			if subscript == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else if index, err := strconv.Atoi(subscript); err == nil {
+				steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			} else {
+				steps = append(steps, jsonPathStep{field: subscript})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q in jsonpath expression", expr[0])
+		}
+	}
+
+	return steps, nil
+}
+
+// Apply a path to a value, returning all the values it selects.
+func evalJSONPathSteps(value interface{}, steps []jsonPathStep) []interface{} {
+	results := []interface{}{value}
+
+	for _, step := range steps {
+		var next []interface{}
+
+		for _, result := range results {
+			switch v := result.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					for _, key := range sortedKeys(v) {
+						next = append(next, v[key])
+					}
+				} else if !step.isIndex {
+					if item, ok := v[step.field]; ok {
+						next = append(next, item)
+					}
+				}
+			case []interface{}:
+				switch {
+				case step.wildcard:
+					next = append(next, v...)
+				case step.isIndex:
+					index := step.index
+					if index < 0 {
+						index += len(v)
+					}
+					if index >= 0 && index < len(v) {
+						next = append(next, v[index])
+					}
+				}
+			}
+		}
+
+		results = next
+	}
+
+	return results
+}
+
+// Format a single value selected by a path. Scalars are printed as
+// they are; objects and lists are printed as JSON.
+func formatJSONPathValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
+
+func executeJSONPathNodes(w io.Writer, nodes []*jsonPathNode, data interface{}) error {
+	for _, node := range nodes {
+		switch {
+		case node.literal:
+			if _, err := io.WriteString(w, node.text); err != nil {
+				return err
+			}
+		case node.isRange:
+			for _, item := range evalJSONPathSteps(data, node.path) {
+				if err := executeJSONPathNodes(w, node.children, item); err != nil {
+					return err
+				}
+			}
+		default:
+			var values []string
+			for _, value := range evalJSONPathSteps(data, node.path) {
+				values = append(values, formatJSONPathValue(value))
+			}
+			if _, err := io.WriteString(w, strings.Join(values, " ")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Execute writes the result of applying the template to data, which
+// should be in generic form (see toGeneric).
+func (jp *JSONPath) Execute(w io.Writer, data interface{}) error {
+	return executeJSONPathNodes(w, jp.nodes, data)
+}
+
+// String returns the result of applying the template to data.
+func (jp *JSONPath) String(data interface{}) (string, error) {
+	var b strings.Builder
+	if err := jp.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
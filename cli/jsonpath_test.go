@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+const jsonPathTestData = `[
+	{"name": "lab", "id": "c1", "hosts": [{"id": "h1"}, {"id": "h2"}], "ready": true, "count": 3},
+	{"name": "prod", "id": "c2", "hosts": [], "ready": false, "count": 0.5}
+]`
+
+func jsonPathTestValue(t *testing.T) interface{} {
+	var data interface{}
+
+	if err := json.Unmarshal([]byte(jsonPathTestData), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestJSONPath(t *testing.T) {
+	data := jsonPathTestValue(t)
+
+	for _, tc := range []struct {
+		template string
+		want     string
+	}{
+		{`{[0].name}`, "lab"},
+		{`{$[1].id}`, "c2"},
+		{`{[-1].name}`, "prod"},
+		{`{[*].name}`, "lab prod"},
+		{`{[0].hosts[*].id}`, "h1 h2"},
+		{`{[0]['name']}`, "lab"},
+		{`{[0].ready}/{[1].ready}`, "true/false"},
+		{`{[0].count} {[1].count}`, "3 0.5"},
+		{`{[0].hosts[0]}`, `{"id":"h1"}`},
+		{`{[0].missing}`, ""},
+		{`{[5].name}`, ""},
+		{`name: {[0].name}`, "name: lab"},
+		{`{range [*]}{.name}{"\n"}{end}`, "lab\nprod\n"},
+		{`{range [*]}{.name}:{range .hosts[*]}{.id},{end};{end}`, "lab:h1,h2,;prod:;"},
+		{`{"}"}`, "}"},
+		{`{@[0].name}`, "lab"},
+		{`{range .}{[0].name}{end}`, "lab"},
+		{`{range @}{[1].id}{end}`, "c2"},
+		{`{range $}{[*].id}{end}`, "c1 c2"},
+		{`{range [0]}{range .}{.name}{end}{end}`, "lab"},
+	} {
+		jp, err := ParseJSONPath(tc.template)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.template, err)
+			continue
+		}
+
+		got, err := jp.String(data)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.template, err)
+			continue
+		}
+
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.template, got, tc.want)
+		}
+	}
+}
+
+func TestJSONPathRangeRoot(t *testing.T) {
+	data := map[string]interface{}{"a": "x", "b": "y"}
+
+	for _, template := range []string{`{range .}{.a}{end}`, `{range @}{.a}{end}`} {
+		jp, err := ParseJSONPath(template)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", template, err)
+			continue
+		}
+
+		got, err := jp.String(data)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", template, err)
+		} else if got != "x" {
+			t.Errorf("%s: got %q, want %q", template, got, "x")
+		}
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	for _, template := range []string{
+		`{.name`,
+		`{end}`,
+		`{range [*]}{.name}`,
+		`{[0}`,
+		`{name}`,
+		`{"unterminated}`,
+	} {
+		if _, err := ParseJSONPath(template); err == nil {
+			t.Errorf("%s: expected an error", template)
+		}
+	}
+}
+
+func TestCustomColumns(t *testing.T) {
+	data := jsonPathTestValue(t)
+
+	var out bytes.Buffer
+	if err := writeCustomColumns(&out, "NAME:.name,FIRST:.hosts[0].id", data); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "NAME FIRST\nlab  h1\nprod <none>\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	if err := writeCustomColumns(&out, "NAME", data); err == nil {
+		t.Errorf("expected an error for a column without a path")
+	}
+}
+
+func TestPrintOutput(t *testing.T) {
+	data := jsonPathTestValue(t)
+	table := func(w io.Writer, wide bool) error {
+		_, err := fmt.Fprintln(w, "NAME\tID")
+		return err
+	}
+
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{"table", "NAME ID\n"},
+		{"jsonpath={[*].id}", "c1 c2\n"},
+		{"custom-columns=NAME:.name", "NAME\nlab\nprod\n"},
+	} {
+		var out bytes.Buffer
+
+		cmd := cobra.Command{}
+		addOutputFlag(&cmd)
+		cmd.SetOut(&out)
+		if err := cmd.Flags().Set("output", tc.format); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := printOutput(&cmd, data, table); err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.format, err)
+		} else if out.String() != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.format, out.String(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/larsks/oaitool/api"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Download logs to <dest>/<name>.tar (or .tar.gz if the api sent us
+// a compressed tarball), and extract them into <dest>/<name> if
+// extract is true. Returns the path to the downloaded file.
+func downloadLogs(ctx *Context, cmd *cobra.Command, clusterid, logsType, hostid, dest, name string, extract bool) (string, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dest, name+".tar")
+	out, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+
+	err = ctx.api.DownloadLogsCtx(cmd.Context(), clusterid, logsType, hostid, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	compressed, err := isGzipFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if compressed {
+		newPath := path + ".gz"
+		if err := os.Rename(path, newPath); err != nil {
+			return "", err
+		}
+		path = newPath
+	}
+
+	log.Infof("wrote %s", path)
+
+	if extract {
+		extractDir := filepath.Join(dest, name)
+		if err := extractTarball(path, extractDir); err != nil {
+			return "", fmt.Errorf("failed to extract %s: %w", path, err)
+		}
+		log.Infof("extracted %s to %s", path, extractDir)
+	}
+
+	return path, nil
+}
+
+func isGzipFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false, nil
+	}
+
+	return magic[0] == 0x1f && magic[1] == 0x8b, nil
+}
+
+// Extract a (possibly gzipped) tarball into dest. Archives nested
+// inside the tarball are left as they are.
+func extractTarball(path, dest string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var reader io.Reader = bufio.NewReader(f)
+
+	compressed, err := isGzipFile(path)
+	if err != nil {
+		return err
+	}
+
+	if compressed {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Don't let the archive write outside of dest.
+		target := filepath.Join(dest, header.Name)
+		if target != filepath.Clean(dest) && !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in archive: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(out, tr)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			log.Debugf("skipping %s in archive", header.Name)
+		}
+	}
+}
+
+// Download the logs for a single host in a cluster.
+func downloadHostLogs(ctx *Context, cmd *cobra.Command, cluster *api.Cluster, host *api.Host, dest string, extract bool) error {
+	if host.LogsCollectedAt.IsZero() {
+		return fmt.Errorf("no logs have been collected for host %s", hostDisplayName(host))
+	}
+
+	name := fmt.Sprintf("%s_%s_logs", cluster.Name, hostDisplayName(host))
+	_, err := downloadLogs(ctx, cmd, cluster.ID, "host", host.ID, dest, name, extract)
+	return err
+}
+
+func addLogsFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("dest", "d", ".", "directory in which to save logs")
+	cmd.Flags().BoolP("extract", "x", false, "extract the downloaded logs")
+}
@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/larsks/oaitool/api"
 	"github.com/larsks/oaitool/version"
@@ -20,23 +24,108 @@ type (
 	}
 )
 
+// Find the cluster named by the --cluster option. If the option isn't
+// set, fall back to the OAI_CLUSTER environment variable and then to
+// the default cluster selected with `cluster use`.
 func getClusterFromFlags(ctx *Context, cmd *cobra.Command) (*api.Cluster, error) {
 	clusterid, err := cmd.Flags().GetString("cluster")
 	if err != nil {
 		return nil, err
 	}
+	if clusterid == "" {
+		clusterid = viper.GetString("cluster")
+	}
 	if clusterid == "" {
 		return nil, fmt.Errorf("no cluster name provided")
 	}
 
-	cluster, err := ctx.api.FindCluster(clusterid)
+	cluster, err := ctx.api.FindClusterCtx(cmd.Context(), clusterid)
 	if err != nil {
 		return nil, err
 	}
 
+	log.Infof("using cluster %s (%s)", cluster.Name, cluster.ID)
 	return cluster, nil
 }
 
+// Return a context that expires after the given number of seconds. A
+// timeout of zero means no deadline.
+func withTimeout(parent context.Context, timeout int) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(parent, time.Duration(timeout)*time.Second)
+	}
+
+	return context.WithCancel(parent)
+}
+
+// Sleep for the given interval, returning early if ctx is cancelled
+// or reaches its deadline.
+func sleepCtx(ctx context.Context, interval time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(interval):
+		return nil
+	}
+}
+
+// Translate a deadline error from one of the wait-for-status loops
+// into something more readable.
+func waitError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out waiting for status")
+	}
+
+	return err
+}
+
+// Read the pull secret from the file named by the --pull-secret
+// option, or fetch it from the api if no file was provided. We can
+// only fetch it when using Red Hat SSO.
+func getPullSecretFromFlags(ctx *Context, cmd *cobra.Command) ([]byte, error) {
+	var ps *api.PullSecret
+
+	pspath, err := cmd.Flags().GetString("pull-secret")
+	if err != nil {
+		return nil, err
+	}
+
+	if pspath != "" {
+		ps, err = api.PullSecretFromFile(pspath)
+	} else if mode := ctx.api.AuthMode(); mode != api.AuthModeRHSSO {
+		return nil, fmt.Errorf("--pull-secret is required when auth mode is %s", mode)
+	} else {
+		ps, err = ctx.api.GetPullSecretCtx(cmd.Context())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ps.ToJSON()
+}
+
+// Read a public key from the file named by the --ssh-public-key
+// option, if any.
+func getSshKeyFromFlags(cmd *cobra.Command) (string, error) {
+	sshKeyFile, err := cmd.Flags().GetString("ssh-public-key")
+	if err != nil {
+		return "", err
+	}
+
+	if sshKeyFile == "" {
+		return "", nil
+	}
+
+	log.Debugf("reading ssh key from %s", sshKeyFile)
+	sshKey, err := ioutil.ReadFile(sshKeyFile)
+	if err != nil {
+		return "", err
+	}
+
+	return string(sshKey), nil
+}
+
 func initLogging(cmd *cobra.Command) error {
 	var loglevel log.Level
 
@@ -106,16 +195,44 @@ func initConfig(cmd *cobra.Command) error {
 		log.Debugf("using config file %s", viper.ConfigFileUsed())
 	}
 
-	return nil
+	return initConfigContext()
 }
 
 func initContext(cmd *cobra.Command, ctx *Context) error {
-	offlinetoken := viper.GetString("offline-token")
 	apiurl := viper.GetString("api-url")
+	auth := api.AuthConfig{
+		Mode:         api.AuthMode(viper.GetString("auth-mode")),
+		OfflineToken: viper.GetString("offline-token"),
+		Token:        viper.GetString("token"),
+		TokenUrl:     viper.GetString("token-url"),
+		ClientID:     viper.GetString("client-id"),
+	}
 
-	apiclient := api.NewApiClient(offlinetoken, apiurl)
-	if apiclient == nil {
-		return fmt.Errorf("failed to create api client")
+	log.Debugf("using auth mode %s", auth.Mode)
+	apiclient, err := api.NewApiClientWithAuth(apiurl, auth)
+	if err != nil {
+		return fmt.Errorf("failed to create api client: %w", err)
+	}
+
+	apiclient.SetTimeout(viper.GetDuration("request-timeout"))
+	apiclient.Retry = api.RetryPolicy{
+		MaxAttempts:    viper.GetInt("retry-max-attempts"),
+		InitialBackoff: viper.GetDuration("retry-backoff"),
+		MaxBackoff:     viper.GetDuration("retry-max-backoff"),
+		Jitter:         viper.GetFloat64("retry-jitter"),
+	}
+	log.Debugf("using retry policy %+v", apiclient.Retry)
+
+	if caBundle := viper.GetString("ca-bundle"); caBundle != "" {
+		log.Debugf("reading ca certificates from %s", caBundle)
+		bundle, err := ioutil.ReadFile(caBundle)
+		if err != nil {
+			return err
+		}
+
+		if err := apiclient.SetCABundle(bundle); err != nil {
+			return fmt.Errorf("%s: %w", caBundle, err)
+		}
 	}
 
 	ctx.api = apiclient
@@ -165,16 +282,45 @@ func NewCmdRoot() *cobra.Command {
 	cmd.PersistentFlags().CountP("verbose", "v", "set logging verbosity")
 	cmd.PersistentFlags().StringP("api-url", "u", "https://api.openshift.com/api/assisted-install/v1", "set logging verbosity")
 
-	if err := viper.BindPFlag("offline-token", cmd.PersistentFlags().Lookup("offline-token")); err != nil {
-		panic(err)
-	}
-	if err := viper.BindPFlag("api-url", cmd.PersistentFlags().Lookup("api-url")); err != nil {
-		panic(err)
+	cmd.PersistentFlags().String("context", "", "use the named context from the config file")
+	cmd.PersistentFlags().String("ca-bundle", "", "path to a file of trusted CA certificates")
+	cmd.PersistentFlags().String("auth-mode", string(api.AuthModeRHSSO), "authentication mode (rhsso, none, static-bearer, custom-oidc)")
+	cmd.PersistentFlags().String("token", "", "bearer token for static-bearer auth mode")
+	cmd.PersistentFlags().String("token-url", "", "token endpoint for custom-oidc auth mode")
+	cmd.PersistentFlags().String("client-id", "", "client id for custom-oidc auth mode")
+	cmd.PersistentFlags().Duration("request-timeout", 2*time.Minute, "time limit for api requests (0 for no limit)")
+	cmd.PersistentFlags().Int("retry-max-attempts", api.DefaultRetryPolicy.MaxAttempts, "maximum number of attempts for idempotent api requests")
+	cmd.PersistentFlags().Duration("retry-backoff", api.DefaultRetryPolicy.InitialBackoff, "delay before retrying a failed api request")
+	cmd.PersistentFlags().Duration("retry-max-backoff", api.DefaultRetryPolicy.MaxBackoff, "maximum delay between retries")
+	cmd.PersistentFlags().Float64("retry-jitter", api.DefaultRetryPolicy.Jitter, "randomize retry delays by up to this fraction")
+
+	for _, name := range []string{
+		"context",
+		"ca-bundle",
+		"offline-token",
+		"api-url",
+		"auth-mode",
+		"token",
+		"token-url",
+		"client-id",
+		"request-timeout",
+		"retry-max-attempts",
+		"retry-backoff",
+		"retry-max-backoff",
+		"retry-jitter",
+	} {
+		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
+			panic(err)
+		}
 	}
 
 	cmd.AddCommand(
 		NewCmdCluster(ctx),
 		NewCmdHost(ctx),
+		NewCmdInfraEnv(ctx),
+		NewCmdApply(ctx),
+		NewCmdDiff(ctx),
+		NewCmdConfig(ctx),
 		NewCmdVersion(ctx),
 	)
 
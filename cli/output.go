@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// A function that writes data as a table. If wide is true it should
+// include additional columns.
+type tableFunc func(w io.Writer, wide bool) error
+
+var outputFormatHelp = "output format: table, wide, json, yaml, jsonpath=<template>, go-template=<template> or custom-columns=<spec>"
+
+// Add the -o/--output option to a command. Commands that use this
+// should produce their output with printOutput.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", "table", outputFormatHelp)
+}
+
+// Convert data into its generic form (maps, slices and scalars) by
+// round-tripping it through JSON. This means templates and yaml
+// output use the same field names as the api.
+func toGeneric(data interface{}) (interface{}, error) {
+	var generic interface{}
+
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(dataJson, &generic); err != nil {
+		return nil, err
+	}
+
+	return generic, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Print data in the format selected by the --output option. The
+// table and wide formats are produced by calling table.
+func printOutput(cmd *cobra.Command, data interface{}, table tableFunc) error {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(cmd.OutOrStdout(), format, data, table)
+}
+
+func writeOutput(out io.Writer, format string, data interface{}, table tableFunc) error {
+	name, arg := format, ""
+	if pos := strings.Index(format, "="); pos >= 0 {
+		name, arg = format[:pos], format[pos+1:]
+	}
+
+	switch name {
+	case "", "table", "wide":
+		w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+		if err := table(w, name == "wide"); err != nil {
+			return err
+		}
+		return w.Flush()
+	case "json":
+		dataJson, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(dataJson))
+		return err
+	case "yaml":
+		generic, err := toGeneric(data)
+		if err != nil {
+			return err
+		}
+		dataYaml, err := yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(dataYaml)
+		return err
+	case "jsonpath":
+		jp, err := ParseJSONPath(arg)
+		if err != nil {
+			return err
+		}
+		generic, err := toGeneric(data)
+		if err != nil {
+			return err
+		}
+		if err := jp.Execute(out, generic); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out)
+		return err
+	case "go-template":
+		tmpl, err := template.New("output").Parse(arg)
+		if err != nil {
+			return err
+		}
+		generic, err := toGeneric(data)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(out, generic)
+	case "custom-columns":
+		return writeCustomColumns(out, arg, data)
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// Write data using a custom-columns spec of the form
+// HEADER:.path,HEADER:.path,... If data is a list, each item in the
+// list is a row.
+func writeCustomColumns(out io.Writer, spec string, data interface{}) error {
+	var headers []string
+	var columns []*JSONPath
+
+	for _, column := range strings.Split(spec, ",") {
+		parts := strings.SplitN(column, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid custom-columns specification: %s", column)
+		}
+
+		path := parts[1]
+		if !strings.HasPrefix(path, "{") {
+			path = fmt.Sprintf("{%s}", path)
+		}
+
+		jp, err := ParseJSONPath(path)
+		if err != nil {
+			return err
+		}
+
+		headers = append(headers, parts[0])
+		columns = append(columns, jp)
+	}
+
+	generic, err := toGeneric(data)
+	if err != nil {
+		return err
+	}
+
+	rows, ok := generic.([]interface{})
+	if !ok {
+		rows = []interface{}{generic}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		var values []string
+		for _, column := range columns {
+			value, err := column.String(row)
+			if err != nil {
+				return err
+			}
+			if value == "" {
+				value = "<none>"
+			}
+			values = append(values, value)
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+
+	return w.Flush()
+}
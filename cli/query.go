@@ -0,0 +1,628 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/larsks/oaitool/api"
+)
+
+// This file implements the query language used by host find. A query
+// is a list of comparisons combined with and, or, not and parentheses:
+//
+//   memory>=64GiB and disk.type=SSD and (status=known or status=insufficient)
+//
+// Fields that have more than one value for a host (e.g. disk.size or
+// ip) match if any of the values match; != and !~ match if none of
+// them do.
+
+type queryFieldKind int
+
+const (
+	fieldString queryFieldKind = iota
+	fieldNumber
+	fieldIP
+)
+
+// A host and its decoded inventory. The inventory is nil if the host
+// hasn't sent one.
+type queryHost struct {
+	host      *api.Host
+	inventory *api.HostInventory
+}
+
+type queryField struct {
+	kind queryFieldKind
+
+	// Compare strings without regard to case.
+	fold bool
+
+	// Return the values of the field for a host. Number fields return
+	// decimal strings.
+	values func(h *queryHost) []string
+}
+
+// Return a field accessor that reads a single value from the
+// inventory.
+func inventoryValue(get func(inventory *api.HostInventory) string) func(h *queryHost) []string {
+	return func(h *queryHost) []string {
+		if h.inventory == nil {
+			return nil
+		}
+		return []string{get(h.inventory)}
+	}
+}
+
+// Return a field accessor that reads one value from each disk in the
+// inventory.
+func diskValues(get func(disk *api.Disks) string) func(h *queryHost) []string {
+	return func(h *queryHost) []string {
+		var values []string
+		if h.inventory == nil {
+			return nil
+		}
+		for i := range h.inventory.Disks {
+			values = append(values, get(&h.inventory.Disks[i]))
+		}
+		return values
+	}
+}
+
+// Return a field accessor that reads one value from each interface in
+// the inventory.
+func interfaceValues(get func(iface *api.Interfaces) string) func(h *queryHost) []string {
+	return func(h *queryHost) []string {
+		var values []string
+		if h.inventory == nil {
+			return nil
+		}
+		for i := range h.inventory.Interfaces {
+			values = append(values, get(&h.inventory.Interfaces[i]))
+		}
+		return values
+	}
+}
+
+func itoa(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
+
+var queryFields = map[string]*queryField{
+	"id": {kind: fieldString, values: func(h *queryHost) []string {
+		return []string{h.host.ID}
+	}},
+	"status": {kind: fieldString, values: func(h *queryHost) []string {
+		return []string{h.host.Status}
+	}},
+	"role": {kind: fieldString, values: func(h *queryHost) []string {
+		return []string{h.host.Role}
+	}},
+	"hostname": {kind: fieldString, values: func(h *queryHost) []string {
+		return []string{h.host.RequestedHostname}
+	}},
+	"vendor": {kind: fieldString, values: inventoryValue(func(inventory *api.HostInventory) string {
+		return inventory.SystemVendor.Manufacturer
+	})},
+	"product": {kind: fieldString, values: inventoryValue(func(inventory *api.HostInventory) string {
+		return inventory.SystemVendor.ProductName
+	})},
+	"serial": {kind: fieldString, values: inventoryValue(func(inventory *api.HostInventory) string {
+		return inventory.SystemVendor.SerialNumber
+	})},
+	"bmc_address": {kind: fieldString, values: inventoryValue(func(inventory *api.HostInventory) string {
+		return inventory.BmcAddress
+	})},
+	"arch": {kind: fieldString, values: inventoryValue(func(inventory *api.HostInventory) string {
+		return inventory.CPU.Architecture
+	})},
+	"cpu.count": {kind: fieldNumber, values: inventoryValue(func(inventory *api.HostInventory) string {
+		return itoa(int64(inventory.CPU.Count))
+	})},
+	"memory": {kind: fieldNumber, values: inventoryValue(func(inventory *api.HostInventory) string {
+		return itoa(inventory.Memory.PhysicalBytes)
+	})},
+	"disk.name": {kind: fieldString, values: diskValues(func(disk *api.Disks) string {
+		return disk.Name
+	})},
+	"disk.type": {kind: fieldString, fold: true, values: diskValues(func(disk *api.Disks) string {
+		return disk.DriveType
+	})},
+	"disk.size": {kind: fieldNumber, values: diskValues(func(disk *api.Disks) string {
+		return itoa(disk.SizeBytes)
+	})},
+	"disk.serial": {kind: fieldString, values: diskValues(func(disk *api.Disks) string {
+		return disk.Serial
+	})},
+	"interface.name": {kind: fieldString, values: interfaceValues(func(iface *api.Interfaces) string {
+		return iface.Name
+	})},
+	"interface.speed": {kind: fieldNumber, values: interfaceValues(func(iface *api.Interfaces) string {
+		return itoa(int64(iface.SpeedMbps))
+	})},
+	"mac": {kind: fieldString, fold: true, values: interfaceValues(func(iface *api.Interfaces) string {
+		return iface.MacAddress
+	})},
+	"ip": {kind: fieldIP, values: func(h *queryHost) []string {
+		var values []string
+		if h.inventory == nil {
+			return nil
+		}
+		for _, iface := range h.inventory.Interfaces {
+			values = append(values, iface.Ipv4Addresses...)
+			values = append(values, iface.Ipv6Addresses...)
+		}
+		return values
+	}},
+}
+
+// Other names for fields.
+var queryFieldAliases = map[string]string{
+	"bmc-address": "bmc_address",
+	"cpus":        "cpu.count",
+	"cpu":         "cpu.count",
+}
+
+// Return the names of the fields a query can use.
+func queryFieldNames() []string {
+	var names []string
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+type queryNode interface {
+	match(h *queryHost) bool
+}
+
+type (
+	queryAnd struct{ left, right queryNode }
+	queryOr  struct{ left, right queryNode }
+	queryNot struct{ node queryNode }
+
+	queryCompare struct {
+		name  string
+		field *queryField
+		op    string
+		value string
+
+		number int64
+		regexp *regexp.Regexp
+		glob   bool
+		ip     net.IP
+		ipnet  *net.IPNet
+	}
+)
+
+func (node *queryAnd) match(h *queryHost) bool {
+	return node.left.match(h) && node.right.match(h)
+}
+
+func (node *queryOr) match(h *queryHost) bool {
+	return node.left.match(h) || node.right.match(h)
+}
+
+func (node *queryNot) match(h *queryHost) bool {
+	return !node.node.match(h)
+}
+
+func (node *queryCompare) match(h *queryHost) bool {
+	// The negative operators match when no value matches the
+	// corresponding positive operator.
+	switch node.op {
+	case "!=":
+		return !node.matchAny(h, "=")
+	case "!~":
+		return !node.matchAny(h, "~")
+	default:
+		return node.matchAny(h, node.op)
+	}
+}
+
+func (node *queryCompare) matchAny(h *queryHost, op string) bool {
+	for _, value := range node.field.values(h) {
+		if node.matchValue(value, op) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (node *queryCompare) matchValue(value, op string) bool {
+	switch node.field.kind {
+	case fieldNumber:
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+
+		switch op {
+		case "=":
+			return number == node.number
+		case "<":
+			return number < node.number
+		case "<=":
+			return number <= node.number
+		case ">":
+			return number > node.number
+		case ">=":
+			return number >= node.number
+		}
+	case fieldIP:
+		ip, _, err := net.ParseCIDR(value)
+		if err != nil {
+			ip = net.ParseIP(value)
+		}
+		if ip == nil {
+			return false
+		}
+
+		if node.ipnet != nil {
+			return node.ipnet.Contains(ip)
+		}
+		return node.ip.Equal(ip)
+	default:
+		want := node.value
+		if node.field.fold {
+			value, want = strings.ToLower(value), strings.ToLower(want)
+		}
+
+		switch {
+		case op == "~":
+			return node.regexp.MatchString(value)
+		case node.glob:
+			matched, _ := path.Match(want, value)
+			return matched
+		default:
+			return value == want
+		}
+	}
+
+	return false
+}
+
+// Check the operator and value of a comparison against the type of
+// its field, and parse the value.
+func newQueryCompare(name, op, value string) (*queryCompare, error) {
+	if alias, ok := queryFieldAliases[name]; ok {
+		name = alias
+	}
+
+	field, ok := queryFields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q: must be one of %v", name, queryFieldNames())
+	}
+
+	if op == "==" {
+		op = "="
+	}
+
+	node := queryCompare{name: name, field: field, op: op, value: value}
+
+	switch field.kind {
+	case fieldNumber:
+		if op == "~" || op == "!~" {
+			return nil, fmt.Errorf("%s is a number and cannot be matched with %s", name, op)
+		}
+
+		number, err := api.ParseSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+		node.number = number
+	case fieldIP:
+		if op != "=" && op != "!=" {
+			return nil, fmt.Errorf("%s can only be compared with = or !=", name)
+		}
+
+		if strings.Contains(value, "/") {
+			_, ipnet, err := net.ParseCIDR(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s: %q is not a valid CIDR", name, value)
+			}
+			node.ipnet = ipnet
+		} else {
+			node.ip = net.ParseIP(value)
+			if node.ip == nil {
+				return nil, fmt.Errorf("invalid value for %s: %q is not a valid IP address", name, value)
+			}
+		}
+	default:
+		switch op {
+		case "=", "!=":
+			node.glob = strings.ContainsAny(value, "*?[")
+			if node.glob {
+				pattern := value
+				if field.fold {
+					pattern = strings.ToLower(pattern)
+				}
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("invalid pattern for %s: %q", name, value)
+				}
+			}
+		case "~", "!~":
+			expr := value
+			if field.fold {
+				expr = "(?i)" + expr
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression for %s: %w", name, err)
+			}
+			node.regexp = re
+		default:
+			return nil, fmt.Errorf("%s is a string and cannot be compared with %s", name, op)
+		}
+	}
+
+	return &node, nil
+}
+
+type queryTokenKind int
+
+const (
+	tokenEOF queryTokenKind = iota
+	tokenWord
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+type queryToken struct {
+	kind  queryTokenKind
+	text  string
+	quote bool
+}
+
+var queryOperators = []string{"==", "!=", "<=", ">=", "!~", "=", "<", ">", "~"}
+
+func isQueryOperatorChar(r rune) bool {
+	return strings.ContainsRune("=!<>~", r)
+}
+
+// Split a query into words, operators and parentheses. An unquoted
+// value after an operator runs up to the next and, or, parenthesis or
+// operator character, so that values such as Dell Inc. can contain
+// spaces; other words end at a space. Words that contain parentheses
+// or operator characters (or values that contain the words and or or)
+// must be quoted with single or double quotes.
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+
+	runes := []rune(query)
+	pos := 0
+
+	for pos < len(runes) {
+		r := runes[pos]
+
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokenLParen, text: "("})
+			pos++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokenRParen, text: ")"})
+			pos++
+		case r == '"' || r == '\'':
+			end := pos + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated string in query: %s", string(runes[pos:]))
+			}
+			tokens = append(tokens, queryToken{kind: tokenWord, text: string(runes[pos+1 : end]), quote: true})
+			pos = end + 1
+		case isQueryOperatorChar(r):
+			rest := string(runes[pos:])
+			found := ""
+			for _, op := range queryOperators {
+				if strings.HasPrefix(rest, op) {
+					found = op
+					break
+				}
+			}
+			if found == "" {
+				return nil, fmt.Errorf("invalid operator in query: %s", rest)
+			}
+			tokens = append(tokens, queryToken{kind: tokenOp, text: found})
+			pos += len([]rune(found))
+		default:
+			afterOp := len(tokens) > 0 && tokens[len(tokens)-1].kind == tokenOp
+
+			end := pos
+			for end < len(runes) && runes[end] != '(' && runes[end] != ')' && !isQueryOperatorChar(runes[end]) {
+				if unicode.IsSpace(runes[end]) && (!afterOp || isQueryKeywordAt(runes, end)) {
+					break
+				}
+				end++
+			}
+			text := strings.TrimRightFunc(string(runes[pos:end]), unicode.IsSpace)
+			tokens = append(tokens, queryToken{kind: tokenWord, text: text})
+			pos = end
+		}
+	}
+
+	return append(tokens, queryToken{kind: tokenEOF}), nil
+}
+
+// Return true if the next word after the spaces at pos is and or or.
+func isQueryKeywordAt(runes []rune, pos int) bool {
+	for pos < len(runes) && unicode.IsSpace(runes[pos]) {
+		pos++
+	}
+
+	end := pos
+	for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '(' && runes[end] != ')' {
+		end++
+	}
+
+	word := string(runes[pos:end])
+	return strings.EqualFold(word, "and") || strings.EqualFold(word, "or")
+}
+
+// A recursive descent parser for queries:
+//
+//	expr       := and ("or" and)*
+//	and        := unary ("and" unary)*
+//	unary      := "not" unary | "(" expr ")" | comparison
+//	comparison := field operator value
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+// Return true (and consume the token) if the next token is the given
+// keyword.
+func (p *queryParser) keyword(word string) bool {
+	token := p.peek()
+	if token.kind == tokenWord && !token.quote && strings.EqualFold(token.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) parseExpr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryOr{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryAnd{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	if p.keyword("not") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{node}, nil
+	}
+
+	if p.peek().kind == tokenLParen {
+		p.next()
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if token := p.next(); token.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ) but found %s", describeQueryToken(token))
+		}
+		return node, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryNode, error) {
+	field := p.next()
+	if field.kind != tokenWord {
+		return nil, fmt.Errorf("expected a field name but found %s", describeQueryToken(field))
+	}
+
+	op := p.next()
+	if op.kind != tokenOp {
+		return nil, fmt.Errorf("expected an operator after %s but found %s", field.text, describeQueryToken(op))
+	}
+
+	value := p.next()
+	if value.kind != tokenWord {
+		return nil, fmt.Errorf("expected a value after %s%s but found %s", field.text, op.text, describeQueryToken(value))
+	}
+
+	return newQueryCompare(strings.ToLower(field.text), op.text, value.text)
+}
+
+func describeQueryToken(token queryToken) string {
+	if token.kind == tokenEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", token.text)
+}
+
+// Parse a query into something we can match hosts against.
+func parseQuery(query string) (queryNode, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := queryParser{tokens: tokens}
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+
+	if token := p.peek(); token.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid query: unexpected %s", describeQueryToken(token))
+	}
+
+	return node, nil
+}
+
+// Return the hosts that match a query.
+func filterHosts(hosts []api.Host, query queryNode) []api.Host {
+	var selected []api.Host
+
+	for i := range hosts {
+		h := queryHost{host: &hosts[i]}
+		if inventory, err := hosts[i].GetInventory(); err == nil {
+			h.inventory = inventory
+		}
+
+		if query.match(&h) {
+			selected = append(selected, hosts[i])
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/larsks/oaitool/api"
+)
+
+var queryTestHosts = []api.Host{
+	{
+		ID:                "h1",
+		RequestedHostname: "node-a",
+		Role:              "master",
+		Status:            "known",
+		Inventory: `{
+			"system_vendor": {"manufacturer": "Dell Inc.", "product_name": "PowerEdge R640", "serial_number": "SN[1]"},
+			"cpu": {"count": 32},
+			"memory": {"physical_bytes": 137438953472},
+			"disks": [
+				{"name": "sda", "drive_type": "HDD", "size_bytes": 2000000000000},
+				{"name": "sdb", "drive_type": "SSD", "size_bytes": 480000000000}
+			],
+			"interfaces": [
+				{"name": "eno1", "mac_address": "52:54:00:00:00:01", "ipv4_addresses": ["192.168.10.21/24"],
+				 "ipv6_addresses": ["fd00::21/64"], "speed_mbps": 10000}
+			]
+		}`,
+	},
+	{
+		ID:                "h2",
+		RequestedHostname: "node-b",
+		Role:              "worker",
+		Status:            "insufficient",
+		Inventory: `{
+			"system_vendor": {"manufacturer": "HPE", "product_name": "ProLiant DL360", "serial_number": "SN2"},
+			"cpu": {"count": 16},
+			"memory": {"physical_bytes": 68719476736},
+			"disks": [
+				{"name": "sda", "drive_type": "HDD", "size_bytes": 1000000000000}
+			],
+			"interfaces": [
+				{"name": "eno1", "mac_address": "52:54:00:00:00:02", "ipv4_addresses": ["192.168.20.22/24"],
+				 "speed_mbps": 1000}
+			]
+		}`,
+	},
+	{
+		ID:     "h3",
+		Role:   "auto-assign",
+		Status: "discovering",
+	},
+}
+
+func TestQuery(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{`id=h1`, "h1"},
+		{`hostname=node-*`, "h1 h2"},
+		{`hostname=node-[ab]`, "h1 h2"},
+		{`hostname~^node-b$`, "h2"},
+		{`hostname!~^node`, "h3"},
+		{`vendor=Dell Inc.`, "h1"},
+		{`product=PowerEdge R640`, "h1"},
+		{`product=PowerEdge R640 and role=master`, "h1"},
+		{`vendor=Dell Inc. or vendor=HPE`, "h1 h2"},
+		{`(vendor=Dell Inc.)`, "h1"},
+		{`vendor="Dell Inc."`, "h1"},
+		{`vendor='Dell Inc.'`, "h1"},
+		{`vendor=Dell`, ""},
+		{`vendor=Dell*`, "h1"},
+		{`serial="SN[1]"`, ""},
+		{`serial=SN?`, "h2"},
+		{`mac=52:54:00:00:00:01`, "h1"},
+		{`MAC=52:54:00:00:00:0*`, "h1 h2"},
+		{`mac=52:54:00:00:00:0A`, ""},
+		{`disk.type=ssd`, "h1"},
+		{`disk.type!=ssd`, "h2 h3"},
+		{`disk.type=HDD`, "h1 h2"},
+		{`disk.size>=1T`, "h1 h2"},
+		{`disk.size>1T`, "h1"},
+		{`disk.size<500G`, "h1"},
+		{`memory>=128GiB`, "h1"},
+		{`memory>=64GiB`, "h1 h2"},
+		{`memory==64GiB`, "h2"},
+		{`cpus>16`, "h1"},
+		{`interface.speed>=10000`, "h1"},
+		{`ip=192.168.10.21`, "h1"},
+		{`ip=fd00::21`, "h1"},
+		{`ip=192.168.0.0/16`, "h1 h2"},
+		{`ip!=192.168.20.0/24`, "h1 h3"},
+		{`not role=master`, "h2 h3"},
+		{`NOT role=master AND status=known`, ""},
+		{`role=master or role=worker and status=known`, "h1"},
+		{`(role=master or role=worker) and status=insufficient`, "h2"},
+		{`not (status=known or status=insufficient)`, "h3"},
+		{`role=auto-assign`, "h3"},
+	} {
+		query, err := parseQuery(tc.query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.query, err)
+			continue
+		}
+
+		var ids []string
+		for _, host := range filterHosts(queryTestHosts, query) {
+			ids = append(ids, host.ID)
+		}
+
+		if got := strings.Join(ids, " "); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		``,
+		`role`,
+		`role=`,
+		`=master`,
+		`role=master and`,
+		`role=master status=known`,
+		`(role=master`,
+		`role=master)`,
+		`role=>master`,
+		`role<master`,
+		`color=red`,
+		`memory~64G`,
+		`memory>=lots`,
+		`ip>192.168.10.1`,
+		`ip=192.168.10`,
+		`ip=192.168.10.0/33`,
+		`hostname~(`,
+		`hostname=[`,
+		`vendor="Dell Inc.`,
+		`role!master`,
+	} {
+		if _, err := parseQuery(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+func TestTokenizeQuery(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{`vendor=Dell Inc.`, []string{"vendor", "=", "Dell Inc."}},
+		{`vendor = Dell Inc.  and role=master`, []string{"vendor", "=", "Dell Inc.", "and", "role", "=", "master"}},
+		{`(product=PowerEdge R640 OR x=y)`, []string{"(", "product", "=", "PowerEdge R640", "OR", "x", "=", "y", ")"}},
+		{`vendor="Rock or Roll"`, []string{"vendor", "=", "Rock or Roll"}},
+		{`not memory>=64GiB`, []string{"not", "memory", ">=", "64GiB"}},
+		{`hostname=Oracle`, []string{"hostname", "=", "Oracle"}},
+	} {
+		tokens, err := tokenizeQuery(tc.query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.query, err)
+			continue
+		}
+
+		var got []string
+		for _, token := range tokens {
+			if token.kind != tokenEOF {
+				got = append(got, token.text)
+			}
+		}
+
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%s: got %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestResolveHostNameMappings(t *testing.T) {
+	hostnames, err := resolveHostNameMappings(queryTestHosts, []hostNameMapping{
+		{Serial: "SN[1]", Name: "master-0", source: "line 2"},
+		{Mac: "52:54:00:00:00:02", Hostname: "node-b", Name: "worker-0", source: "line 3"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, hostname := range hostnames {
+		got = append(got, hostname.ID+"="+hostname.HostName)
+	}
+	if strings.Join(got, " ") != "h1=master-0 h2=worker-0" {
+		t.Errorf("got %q", got)
+	}
+
+	_, err = resolveHostNameMappings(queryTestHosts, []hostNameMapping{
+		{Serial: "SN*", Name: "a", source: "line 2"},
+		{Hostname: "node-a", Name: "b", source: "line 3"},
+		{Mac: "52:54:00:00:00:01", Name: "c", source: "line 4"},
+		{BmcAddress: "10.0.0.9", Name: "d", source: "line 5"},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, problem := range []string{
+		"line 2: no host matches serial=SN*",
+		"line 4: host h1 was already matched by line 3",
+		"line 5: no host matches bmc_address=10.0.0.9",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error %q does not mention %q", err, problem)
+		}
+	}
+}
+
+func TestRenderHostNames(t *testing.T) {
+	cluster := api.Cluster{
+		Hosts: []api.Host{
+			{ID: "h1", Role: "worker", Inventory: `{"interfaces": [{"mac_address": "52:54:00:00:00:01"}]}`},
+			{ID: "h2", Role: "master", Inventory: `{"interfaces": [{"mac_address": "52:54:00:00:00:02"}]}`},
+			{ID: "h3", Role: "bootstrap", Inventory: `{"interfaces": [{"mac_address": "52:54:00:00:00:03"}]}`},
+			{ID: "h4", Role: "", Inventory: `{"interfaces": [{"mac_address": "52:54:00:00:00:04"}]}`},
+			{ID: "h5", Role: "auto-assign", Inventory: `{"interfaces": [{"mac_address": "52:54:00:00:00:05"}]}`},
+		},
+	}
+
+	hostnames, err := renderHostNames(&cluster, "{{.Role}}-{{.RoleIndex}}", "role", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, hostname := range hostnames {
+		got = append(got, hostname.ID+"="+hostname.HostName)
+	}
+	want := "h2=master-0 h3=master-1 h4=auto-assign-0 h5=auto-assign-1 h1=worker-0"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+}
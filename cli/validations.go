@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/larsks/oaitool/api"
+	"github.com/spf13/cobra"
+)
+
+// Return the validations selected by the --failing and --category
+// options.
+func getValidationsFromFlags(cmd *cobra.Command, info api.ValidationsInfo) ([]api.Validation, error) {
+	var selected []api.Validation
+
+	failing, err := cmd.Flags().GetBool("failing")
+	if err != nil {
+		return nil, err
+	}
+
+	category, err := cmd.Flags().GetString("category")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, validation := range info.All() {
+		if failing && !validation.Failing() {
+			continue
+		}
+
+		if category != "" && validation.Category != category {
+			continue
+		}
+
+		selected = append(selected, validation)
+	}
+
+	return selected, nil
+}
+
+func validationsTable(validations []api.Validation) tableFunc {
+	return func(w io.Writer, wide bool) error {
+		for _, validation := range validations {
+			fmt.Fprintf(w, "%s\t%s\t%s", validation.Category, validation.ID, validation.Status)
+			if wide || validation.Status != "success" {
+				fmt.Fprintf(w, "\t%s", validation.Message)
+			}
+			fmt.Fprintln(w)
+		}
+
+		return nil
+	}
+}
+
+func addValidationsFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("failing", false, "only show failing validations")
+	cmd.Flags().String("category", "", "only show validations in this category (e.g. network, hardware)")
+	addOutputFlag(cmd)
+}
@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/larsks/oaitool/api"
+	"github.com/spf13/cobra"
+)
+
+// Problem priorities. Problems with a lower priority should be fixed
+// first, since fixing them often fixes the others.
+const (
+	priorityHosts = iota + 1
+	priorityConfig
+	priorityClusterValidation
+	priorityHostStatus
+	priorityHostValidation
+)
+
+type (
+	problem struct {
+		Priority int    `json:"priority"`
+		Subject  string `json:"subject"`
+		Message  string `json:"message"`
+		Hint     string `json:"hint,omitempty"`
+	}
+
+	diagnosis struct {
+		Cluster    string    `json:"cluster"`
+		Status     string    `json:"status"`
+		StatusInfo string    `json:"status_info"`
+		Problems   []problem `json:"problems"`
+	}
+)
+
+// Cluster validations that report the same thing as the checks in
+// diagnoseCluster, so we don't report them twice.
+var diagnosedValidations = []string{
+	"api-vip-defined",
+	"api-vips-defined",
+	"ingress-vip-defined",
+	"ingress-vips-defined",
+	"machine-cidr-defined",
+	"sufficient-masters-count",
+	"all-hosts-are-ready-to-install",
+}
+
+// Host states that don't need any attention.
+var healthyHostStatus = []string{
+	"known",
+	"preparing-for-installation",
+	"preparing-successful",
+	"installing",
+	"installing-in-progress",
+	"installed",
+	"added-to-existing-cluster",
+}
+
+// Look at a cluster and its hosts, and return a list of the things
+// that are keeping it from being ready to install, most important
+// first.
+func diagnoseCluster(cluster *api.Cluster) ([]problem, error) {
+	var problems []problem
+
+	add := func(priority int, subject, message, hint string) {
+		problems = append(problems, problem{priority, subject, message, hint})
+	}
+
+	// Host count vs high availability mode.
+	_, candidates := cluster.CountMasters()
+	switch {
+	case len(cluster.Hosts) == 0:
+		add(priorityHosts, "cluster", "no hosts have registered with the cluster",
+			"boot your hosts from the discovery image (see cluster get-image-url)")
+	case cluster.HighAvailabilityMode == "None" && len(cluster.Hosts) != 1:
+		add(priorityHosts, "cluster",
+			fmt.Sprintf("single node clusters need exactly 1 host, but there are %d", len(cluster.Hosts)),
+			"delete the extra hosts (see host delete)")
+	case cluster.HighAvailabilityMode != "None" && candidates < 3:
+		add(priorityHosts, "cluster",
+			fmt.Sprintf("clusters need 3 control plane hosts, but only %d host(s) can be masters",
+				candidates),
+			"boot more hosts from the discovery image, or change host roles")
+	}
+
+	// Cluster configuration.
+	if !cluster.UserManagedNetworking {
+		if len(cluster.MachineNetworkCidrs()) == 0 {
+			add(priorityConfig, "cluster", "no machine network is defined",
+				"set one with cluster update --machine-network, or wait for hosts to report their networks")
+		}
+
+		if !cluster.VipDhcpAllocation && cluster.HighAvailabilityMode != "None" {
+			if cluster.ApiVip == "" {
+				add(priorityConfig, "cluster", "the API VIP is not set", "set it with cluster set-vips")
+			}
+			if cluster.IngressVip == "" {
+				add(priorityConfig, "cluster", "the ingress VIP is not set", "set it with cluster set-vips")
+			}
+		}
+	}
+
+	if cluster.BaseDNSDomain == "" {
+		add(priorityConfig, "cluster", "the base DNS domain is not set",
+			"set it with cluster update --base-domain")
+	}
+
+	if !cluster.PullSecretSet {
+		add(priorityConfig, "cluster", "the pull secret is not set",
+			"set it with cluster update --pull-secret")
+	}
+
+	info, err := cluster.GetValidationsInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cluster validations: %w", err)
+	}
+
+	for _, validation := range info.FailingValidations() {
+		if api.ValInList(validation.ID, diagnosedValidations) {
+			continue
+		}
+		add(priorityClusterValidation, "cluster", validation.Message, "")
+	}
+
+	// Hosts.
+	for i := range cluster.Hosts {
+		host := &cluster.Hosts[i]
+		subject := fmt.Sprintf("host %s", hostDisplayName(host))
+
+		if !api.ValInList(host.Status, healthyHostStatus) {
+			message := fmt.Sprintf("host is %s", host.Status)
+			if host.StatusInfo != "" {
+				message = fmt.Sprintf("%s: %s", message, host.StatusInfo)
+			}
+
+			hint := ""
+			switch host.Status {
+			case "disconnected":
+				hint = "check that the host is running and can reach the api"
+			case "disabled":
+				hint = "enable the host or delete it"
+			case "pending-for-input":
+				hint = "set the missing cluster configuration (see above)"
+			}
+
+			add(priorityHostStatus, subject, message, hint)
+		}
+
+		info, err := host.GetValidationsInfo()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse validations for %s: %w", subject, err)
+		}
+
+		for _, validation := range info.FailingValidations() {
+			add(priorityHostValidation, subject, validation.Message, "")
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Priority < problems[j].Priority
+	})
+
+	return problems, nil
+}
+
+func NewCmdClusterWhy(ctx *Context) *cobra.Command {
+	cmd := cobra.Command{
+		Use:           "why",
+		Short:         "Explain why a cluster is not ready to install",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cluster, err := getClusterFromFlags(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			problems, err := diagnoseCluster(cluster)
+			if err != nil {
+				return err
+			}
+
+			result := diagnosis{
+				Cluster:    cluster.Name,
+				Status:     cluster.Status,
+				StatusInfo: cluster.StatusInfo,
+				Problems:   problems,
+			}
+
+			return printOutput(cmd, result, func(w io.Writer, wide bool) error {
+				fmt.Fprintf(w, "cluster %s is %s", cluster.Name, cluster.Status)
+				if cluster.StatusInfo != "" {
+					fmt.Fprintf(w, ": %s", cluster.StatusInfo)
+				}
+				fmt.Fprintln(w)
+
+				if len(problems) == 0 {
+					fmt.Fprintln(w, "no problems found")
+					return nil
+				}
+
+				fmt.Fprintln(w)
+				for i, problem := range problems {
+					fmt.Fprintf(w, "%d.\t[%s]\t%s\n", i+1, problem.Subject, problem.Message)
+					if problem.Hint != "" {
+						fmt.Fprintf(w, "\t\t-> %s\n", problem.Hint)
+					}
+				}
+
+				return nil
+			})
+		},
+	}
+
+	addOutputFlag(&cmd)
+
+	return &cmd
+}
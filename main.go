@@ -1,11 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+
 	"github.com/larsks/oaitool/cli"
 	"github.com/spf13/cobra"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	root := cli.NewCmdRoot()
-	cobra.CheckErr(root.Execute())
+	err := root.ExecuteContext(ctx)
+	if errors.Is(err, cli.ErrDrift) {
+		stop()
+		os.Exit(2)
+	}
+	cobra.CheckErr(err)
 }